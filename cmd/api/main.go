@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
@@ -19,6 +22,7 @@ import (
 
 	"github.com/shubhranka/spark_api/internal/data"    // <-- CHECK YOUR PATH
 	"github.com/shubhranka/spark_api/internal/handler" // <-- CHECK YOUR PATH
+	"github.com/shubhranka/spark_api/internal/storage"
 )
 
 func main() {
@@ -45,8 +49,60 @@ func main() {
 	// Initialize dependencies
 	userModel := data.UserModel{DB: db}
 	profileModel := data.ProfileModel{DB: db}
-	matchModel := data.MatchModel{DB: db}
-	conversationModel := data.ConversationModel{DB: db}
+	matchModel := data.MatchModel{
+		DB:             db,
+		PassCooldown:   durationFromEnv("MATCH_PASS_COOLDOWN", 0),
+		DailyBatchSize: intFromEnv("DAILY_MATCH_COUNT", data.DefaultDailyBatchSize),
+		Weights: data.MatchWeights{
+			SharedInterests: floatFromEnv("MATCH_WEIGHT_SHARED_INTERESTS", data.DefaultMatchWeights.SharedInterests),
+			InterestRarity:  floatFromEnv("MATCH_WEIGHT_INTEREST_RARITY", data.DefaultMatchWeights.InterestRarity),
+			Recency:         floatFromEnv("MATCH_WEIGHT_RECENCY", data.DefaultMatchWeights.Recency),
+			Completeness:    floatFromEnv("MATCH_WEIGHT_COMPLETENESS", data.DefaultMatchWeights.Completeness),
+		},
+	}
+	if matchModel.DailyBatchSize > data.MaxMatchPageSize {
+		log.Printf("DAILY_MATCH_COUNT %d is over the maximum of %d, suggesting %d a day", matchModel.DailyBatchSize, data.MaxMatchPageSize, data.MaxMatchPageSize)
+		matchModel.DailyBatchSize = data.MaxMatchPageSize
+	}
+	conversationModel := data.ConversationModel{
+		DB:                      db,
+		ReactionsAcceptRequests: os.Getenv("REACTIONS_ACCEPT_REQUESTS") == "true",
+	}
+	presenceModel := data.PresenceModel{DB: db}
+
+	// Where uploaded files are kept
+	blobStore, err := initializeBlobStore()
+	if err != nil {
+		log.Fatalf("Blob store initialization failed: %v", err)
+	}
+
+	// Expire conversation requests that were never answered
+	pendingTTL := durationFromEnv("PENDING_REQUEST_TTL", 72*time.Hour)
+	sweepInterval := durationFromEnv("PENDING_SWEEP_INTERVAL", 10*time.Minute)
+	go handler.RunPendingSweeper(context.Background(), conversationModel, pendingTTL, sweepInterval)
+
+	// Pick everyone's suggestions for the day ahead of their first visit
+	go handler.RunDailyMatchJob(context.Background(), matchModel, durationFromEnv("DAILY_MATCH_INTERVAL", 15*time.Minute))
+
+	// Keep WebSockets alive behind the load balancer and drop dead ones
+	handler.WSHub.SetHeartbeat(
+		durationFromEnv("WS_PING_INTERVAL", handler.DefaultPingInterval),
+		durationFromEnv("WS_PONG_WAIT", handler.DefaultPongWait),
+	)
+
+	// Track who is online, across every replica
+	handler.WSHub.EnablePresence(presenceModel, durationFromEnv("PRESENCE_HEARTBEAT", 30*time.Second))
+
+	// With more than one replica, WebSocket events must travel through Postgres
+	if os.Getenv("WS_BROKER") == "postgres" {
+		broker, err := handler.NewPostgresBroker(db, os.Getenv("DATABASE_URL"))
+		if err != nil {
+			log.Fatalf("WebSocket broker initialization failed: %v", err)
+		}
+		handler.WSHub.SetBroker(broker)
+		defer broker.Close()
+		fmt.Println("WebSocket events are fanned out through Postgres LISTEN/NOTIFY.")
+	}
 
 	// Setup Gin router
 	router := gin.Default()
@@ -57,6 +113,7 @@ func main() {
 		c.Set("profileModel", profileModel)
 		c.Set("matchModel", matchModel)
 		c.Set("conversationModel", conversationModel)
+		c.Set("blobStore", blobStore)
 		c.Set("authClient", authClient)
 		c.Set("db", db)
 		c.Next()
@@ -68,6 +125,8 @@ func main() {
 		v1.GET("/ping", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"message": "pong"})
 		})
+		// Downloads through signed URLs of the filesystem blob store
+		v1.GET("/blobs/*key", handler.ServeBlob)
 
 		wsRoutes := v1.Group("/ws")
 		wsRoutes.Use(func(c *gin.Context) { // A simpler middleware for WS
@@ -78,6 +137,7 @@ func main() {
 		})
 		{
 			wsRoutes.GET("/chat/:id", handler.HandleWebSocketConnection)
+			wsRoutes.GET("/inbox", handler.HandleInboxConnection)
 		}
 
 		// This group handles the initial user sync
@@ -92,23 +152,66 @@ func main() {
 		apiRoutes.Use(handler.AuthMiddleware(authClient))
 		{
 			apiRoutes.GET("/me", handler.GetMe)
+			apiRoutes.PUT("/me/privacy", handler.UpdatePrivacy)
+			apiRoutes.GET("/me/photos", handler.GetMyPhotos)
+			apiRoutes.POST("/me/photos", handler.UploadPhoto)
+			apiRoutes.PUT("/me/photos/order", handler.ReorderPhotos)
+			apiRoutes.DELETE("/me/photos/:photoId", handler.DeletePhoto)
+			apiRoutes.PUT("/me/audio-intro", handler.UploadAudioIntro)
+			apiRoutes.DELETE("/me/audio-intro", handler.DeleteAudioIntro)
 			apiRoutes.POST("/onboarding", handler.CompleteOnboarding)
 
 			// The new matches route
 			apiRoutes.GET("/matches", handler.GetMatches)
+			apiRoutes.POST("/matches/:userId/like", handler.LikeMatch)
+			apiRoutes.POST("/matches/:userId/pass", handler.PassMatch)
 			apiRoutes.GET("/users/:id", handler.GetUserProfile)
 
+			apiRoutes.GET("/attachments/:id", handler.GetAttachmentURL)
+
 			convRoutes := apiRoutes.Group("/conversations")
 			{
 				convRoutes.POST("/start", handler.StartConversation)
 				convRoutes.GET("", handler.GetConversations)
 				convRoutes.POST("/:id/messages", handler.SendMessage)
+				convRoutes.GET("/:id/messages", handler.GetConversationMessages)
+				convRoutes.PATCH("/:id/messages/:msgId", handler.EditMessage)
+				convRoutes.DELETE("/:id/messages/:msgId", handler.DeleteMessage)
+				convRoutes.PUT("/:id/messages/:msgId/reaction", handler.SetReaction)
+				convRoutes.DELETE("/:id/messages/:msgId/reaction", handler.RemoveReaction)
+				convRoutes.POST("/:id/attachments", handler.UploadAttachment)
+				convRoutes.POST("/:id/read", handler.MarkConversationRead)
 				convRoutes.GET("/:id", handler.GetConversationDetails)
+				convRoutes.POST("/:id/block", handler.BlockConversation)
+				convRoutes.POST("/:id/unblock", handler.UnblockConversation)
+				convRoutes.POST("/:id/decline", handler.DeclineConversation)
 				// We will add the other conversation endpoints here in the next steps
 			}
 		}
 	}
 
+	// Routes whose responses changed shape, next to the /v1 versions kept for existing clients
+	v2 := router.Group("/v2")
+	v2.Use(handler.AuthMiddleware(authClient))
+	{
+		// A page of matches with a cursor, where /v1/matches returns a bare array
+		v2.GET("/matches", handler.GetMatchesPage)
+	}
+
+	// Operational endpoints live on a separate listener that is not exposed to the public
+	internalAddr := os.Getenv("INTERNAL_ADDR")
+	if internalAddr == "" {
+		internalAddr = "127.0.0.1:8081"
+	}
+	internalRouter := gin.Default()
+	internalRouter.GET("/v1/ws/stats", handler.GetHubStats)
+	go func() {
+		log.Printf("Starting internal server on %s\n", internalAddr)
+		if err := internalRouter.Run(internalAddr); err != nil {
+			log.Printf("Internal server stopped: %v", err)
+		}
+	}()
+
 	// Start the server
 	port := os.Getenv("API_PORT")
 	if port == "" {
@@ -136,6 +239,82 @@ func connectDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// initializeBlobStore helper function. BLOB_STORE=s3 selects an S3-compatible bucket,
+// anything else stores files on local disk for development.
+func initializeBlobStore() (storage.BlobStore, error) {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		cfg := storage.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+		if cfg.Endpoint == "" || cfg.Region == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT, S3_REGION and S3_BUCKET must be set")
+		}
+		return storage.NewS3Store(cfg), nil
+	}
+
+	dir := os.Getenv("BLOB_DIR")
+	if dir == "" {
+		dir = "./uploads"
+	}
+	baseURL := os.Getenv("BLOB_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080/v1/blobs"
+	}
+	secret := []byte(os.Getenv("BLOB_SIGNING_KEY"))
+	if len(secret) == 0 {
+		log.Println("Warning: BLOB_SIGNING_KEY is not set, download URLs will stop working on restart.")
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return storage.NewFilesystemStore(dir, baseURL, secret)
+}
+
+// durationFromEnv reads a duration like "72h" from the environment, falling back to a default.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// floatFromEnv reads a number like "0.25" from the environment, falling back to a default.
+func floatFromEnv(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("Invalid %s %q, using default %g", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+// intFromEnv reads a whole number like "10" from the environment, falling back to a default.
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
 // initializeFirebase helper function
 func initializeFirebase() (*auth.Client, error) {
 	keyDataString := os.Getenv("KEY_JSON")
@@ -0,0 +1,346 @@
+// Package audio measures the length of uploaded audio clips without decoding them.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Format is a container format Duration understands.
+type Format int
+
+const (
+	MP3 Format = iota
+	MP4        // Including M4A
+	Ogg        // Opus or Vorbis
+	WAV
+)
+
+var ErrUnreadable = errors.New("could not read the audio duration")
+
+// Duration returns how long a clip plays, from its container headers.
+func Duration(content []byte, format Format) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	switch format {
+	case MP3:
+		d, err = mp3Duration(content)
+	case MP4:
+		d, err = mp4Duration(content)
+	case Ogg:
+		d, err = oggDuration(content)
+	case WAV:
+		d, err = wavDuration(content)
+	default:
+		err = ErrUnreadable
+	}
+	if err == nil && d <= 0 {
+		err = ErrUnreadable
+	}
+	return d, err
+}
+
+// mp4Duration reads the duration from the movie header (moov/mvhd) box.
+func mp4Duration(b []byte) (time.Duration, error) {
+	moov := findBox(b, "moov")
+	if moov == nil {
+		return 0, ErrUnreadable
+	}
+	mvhd := findBox(moov, "mvhd")
+	if len(mvhd) < 20 {
+		return 0, ErrUnreadable
+	}
+
+	var timescale, duration uint64
+	if mvhd[0] == 1 { // Version 1 uses 64-bit times
+		if len(mvhd) < 32 {
+			return 0, ErrUnreadable
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+	if duration == math.MaxUint32 || duration == math.MaxUint64 { // All ones means unknown
+		return 0, ErrUnreadable
+	}
+	return ticksToDuration(duration, timescale)
+}
+
+// findBox returns the payload of the first box of the given type among sibling boxes.
+func findBox(b []byte, boxType string) []byte {
+	for len(b) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(b[0:4]))
+		header := uint64(8)
+		switch size {
+		case 0: // Extends to the end
+			size = uint64(len(b))
+		case 1: // 64-bit size follows the type
+			if len(b) < 16 {
+				return nil
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(b)) {
+			return nil
+		}
+		if string(b[4:8]) == boxType {
+			return b[header:size]
+		}
+		b = b[size:]
+	}
+	return nil
+}
+
+// wavDuration divides the size of the data chunk by the byte rate of the fmt chunk.
+func wavDuration(b []byte) (time.Duration, error) {
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return 0, ErrUnreadable
+	}
+
+	var byteRate uint32
+	for b = b[12:]; len(b) >= 8; {
+		id := string(b[0:4])
+		size := binary.LittleEndian.Uint32(b[4:8])
+		body := b[8:]
+		switch id {
+		case "fmt ":
+			if len(body) < 12 {
+				return 0, ErrUnreadable
+			}
+			byteRate = binary.LittleEndian.Uint32(body[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, ErrUnreadable
+			}
+			// Streamed files may not know the data size, use what is there
+			if uint64(size) > uint64(len(body)) {
+				size = uint32(len(body))
+			}
+			return ticksToDuration(uint64(size), uint64(byteRate))
+		}
+		next := 8 + uint64(size) + uint64(size%2) // Chunks are padded to an even size
+		if next > uint64(len(b)) {
+			break
+		}
+		b = b[next:]
+	}
+	return 0, ErrUnreadable
+}
+
+// oggDuration reads the sample rate from the codec header in the first page and the
+// sample count from the granule position of the stream's last page. Every page is checked,
+// so a capture pattern inside packet data is never mistaken for a page.
+func oggDuration(b []byte) (time.Duration, error) {
+	var rate, preSkip, granule uint64
+	var serial uint32
+	for first := true; len(b) > 0; first = false {
+		page, err := nextOggPage(b)
+		if err != nil {
+			return 0, err
+		}
+		b = b[len(page.raw):]
+
+		if first {
+			// The stream starts with the codec's identification header, alone on the first page
+			if page.headerType&oggFirstPage == 0 {
+				return 0, ErrUnreadable
+			}
+			serial = page.serial
+			switch {
+			case bytes.HasPrefix(page.body, []byte("OpusHead")) && len(page.body) >= 19:
+				rate = 48000 // Opus granule positions always count 48 kHz samples
+				preSkip = uint64(binary.LittleEndian.Uint16(page.body[10:12]))
+			case bytes.HasPrefix(page.body, []byte("\x01vorbis")) && len(page.body) >= 30:
+				rate = uint64(binary.LittleEndian.Uint32(page.body[12:16]))
+			default:
+				return 0, ErrUnreadable
+			}
+			continue
+		}
+		// Pages of other streams are skipped, and so are pages on which no packet ends
+		if page.serial == serial && page.granule != oggNoGranule {
+			granule = page.granule
+		}
+	}
+
+	if rate == 0 || granule <= preSkip {
+		return 0, ErrUnreadable
+	}
+	return ticksToDuration(granule-preSkip, rate)
+}
+
+const (
+	oggHeaderSize = 27
+	oggFirstPage  = 0x02 // Header type flag of a stream's first page
+	oggNoGranule  = math.MaxUint64
+)
+
+// oggPage is one page of an Ogg stream.
+type oggPage struct {
+	raw        []byte // The whole page, header included
+	body       []byte
+	headerType byte
+	granule    uint64
+	serial     uint32
+}
+
+// nextOggPage reads the page at the start of b, checking its capture pattern, length and checksum.
+func nextOggPage(b []byte) (oggPage, error) {
+	if len(b) < oggHeaderSize || string(b[0:4]) != "OggS" || b[4] != 0 {
+		return oggPage{}, ErrUnreadable
+	}
+	header := oggHeaderSize + int(b[26])
+	if len(b) < header {
+		return oggPage{}, ErrUnreadable
+	}
+	size := header
+	for _, lacing := range b[oggHeaderSize:header] {
+		size += int(lacing)
+	}
+	if len(b) < size {
+		return oggPage{}, ErrUnreadable
+	}
+
+	raw := b[:size]
+	if oggChecksum(raw) != binary.LittleEndian.Uint32(raw[22:26]) {
+		return oggPage{}, ErrUnreadable
+	}
+	return oggPage{
+		raw:        raw,
+		body:       raw[header:],
+		headerType: raw[5],
+		granule:    binary.LittleEndian.Uint64(raw[6:14]),
+		serial:     binary.LittleEndian.Uint32(raw[14:18]),
+	}, nil
+}
+
+// oggCRCTable is for the CRC-32 of Ogg pages: polynomial 0x04c11db7, not bit-reflected,
+// unlike the one in hash/crc32.
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggChecksum computes a page's checksum, which covers the page with its checksum field zeroed.
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for i, c := range page {
+		if i >= 22 && i < 26 {
+			c = 0
+		}
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
+
+// ticksToDuration converts a count of ticks at rate per second, such as samples or bytes, into
+// a duration. Headers are untrusted, so a count too large for a time.Duration is an error
+// rather than something that wraps around into a plausible length.
+func ticksToDuration(ticks, rate uint64) (time.Duration, error) {
+	if rate == 0 {
+		return 0, ErrUnreadable
+	}
+	hi, lo := bits.Mul64(ticks, uint64(time.Second))
+	if hi >= rate { // The quotient would not fit in 64 bits
+		return 0, ErrUnreadable
+	}
+	nanos, _ := bits.Div64(hi, lo, rate)
+	if nanos > math.MaxInt64 {
+		return 0, ErrUnreadable
+	}
+	return time.Duration(nanos), nil
+}
+
+// MPEG audio tables for Layer III, in kbit/s and Hz
+var (
+	mpeg1Bitrates  = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2Bitrates  = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mpegSampleRate = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// mp3Duration walks the Layer III frames, adding up the samples each one holds.
+// This is exact for variable bitrate files too.
+func mp3Duration(b []byte) (time.Duration, error) {
+	// Skip an ID3v2 tag, whose size is stored as a syncsafe integer
+	if len(b) >= 10 && string(b[0:3]) == "ID3" {
+		size := int(b[6]&0x7f)<<21 | int(b[7]&0x7f)<<14 | int(b[8]&0x7f)<<7 | int(b[9]&0x7f)
+		size += 10
+		if b[5]&0x10 != 0 { // Footer present
+			size += 10
+		}
+		if size > len(b) {
+			return 0, ErrUnreadable
+		}
+		b = b[size:]
+	}
+
+	var total time.Duration
+	frames := 0
+	for len(b) >= 4 {
+		if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+			if frames == 0 {
+				// Tolerate junk before the first frame
+				b = b[1:]
+				continue
+			}
+			break // Trailing tag or garbage
+		}
+
+		version := int(b[1]>>3) & 3
+		layer := int(b[1]>>1) & 3
+		bitrateIndex := int(b[2] >> 4)
+		rateIndex := int(b[2]>>2) & 3
+		padding := int(b[2]>>1) & 1
+		rates, ok := mpegSampleRate[version]
+		if !ok || layer != 1 || rateIndex == 3 {
+			if frames == 0 {
+				b = b[1:]
+				continue
+			}
+			break
+		}
+
+		sampleRate := rates[rateIndex]
+		bitrate, samples, coefficient := mpeg1Bitrates[bitrateIndex], 1152, 144
+		if version != 3 {
+			bitrate, samples, coefficient = mpeg2Bitrates[bitrateIndex], 576, 72
+		}
+		if bitrate == 0 {
+			break // Free-format or invalid
+		}
+
+		frameLen := coefficient*bitrate*1000/sampleRate + padding
+		if frameLen < 4 || frameLen > len(b) {
+			break
+		}
+		total += time.Duration(samples) * time.Second / time.Duration(sampleRate)
+		frames++
+		b = b[frameLen:]
+	}
+
+	if frames == 0 {
+		return 0, ErrUnreadable
+	}
+	return total, nil
+}
@@ -0,0 +1,181 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// oggPageBytes builds one Ogg page around a body of at most one packet.
+func oggPageBytes(headerType byte, granule uint64, serial, sequence uint32, body []byte) []byte {
+	var lacing []byte
+	n := len(body)
+	for ; n >= 255; n -= 255 {
+		lacing = append(lacing, 255)
+	}
+	lacing = append(lacing, byte(n))
+
+	page := make([]byte, oggHeaderSize, oggHeaderSize+len(lacing)+len(body))
+	copy(page, "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	binary.LittleEndian.PutUint32(page[18:], sequence)
+	page[26] = byte(len(lacing))
+	page = append(append(page, lacing...), body...)
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+	return page
+}
+
+// opusStream builds an Opus stream whose last page ends at the given granule position.
+func opusStream(preSkip uint16, granules ...uint64) []byte {
+	head := []byte("OpusHead\x01\x01\x00\x00\x80\xbb\x00\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint16(head[10:], preSkip)
+	stream := oggPageBytes(oggFirstPage, 0, 7, 0, head)
+	stream = append(stream, oggPageBytes(0, 0, 7, 1, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	for i, granule := range granules {
+		stream = append(stream, oggPageBytes(0, granule, 7, uint32(2+i), bytes.Repeat([]byte{0xfc}, 300))...)
+	}
+	return stream
+}
+
+// mp4WithDuration builds a file whose movie header gives the duration in the given timescale.
+func mp4WithDuration(version byte, timescale uint32, duration uint64) []byte {
+	mvhd := make([]byte, 100)
+	mvhd[0] = version
+	if version == 1 {
+		binary.BigEndian.PutUint32(mvhd[20:], timescale)
+		binary.BigEndian.PutUint64(mvhd[24:], duration)
+	} else {
+		binary.BigEndian.PutUint32(mvhd[12:], timescale)
+		binary.BigEndian.PutUint32(mvhd[16:], uint32(duration))
+	}
+	box := func(boxType string, payload []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+		return append(append(b, boxType...), payload...)
+	}
+	return append(box("ftyp", []byte("M4A \x00\x00\x02\x00")), box("moov", box("mvhd", mvhd))...)
+}
+
+func TestDuration(t *testing.T) {
+	wav := readFixture(t, "clip.wav")
+	mp3 := readFixture(t, "clip.mp3")
+	ogg := readFixture(t, "clip.ogg")
+	m4a := readFixture(t, "clip.m4a")
+
+	// The same Ogg file with "OggS" in the packet data of its last page
+	oggCapture := append([]byte{}, ogg...)
+	lastPage := 4227
+	copy(oggCapture[lastPage+200:], "OggS\x00\x04\xff\xff\xff\xff\xff\xff\x00\x00")
+	binary.LittleEndian.PutUint32(oggCapture[lastPage+22:], oggChecksum(oggCapture[lastPage:]))
+
+	oggCorrupt := append([]byte{}, ogg...)
+	oggCorrupt[lastPage+500] ^= 0xff
+
+	// Another logical stream multiplexed in, whose pages must not count
+	muxed := opusStream(312, 48312)
+	muxed = append(muxed, oggPageBytes(0, 48000*60, 99, 0, []byte("video"))...)
+
+	tests := []struct {
+		name    string
+		content []byte
+		format  Format
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "WAV", content: wav, format: WAV, want: 500 * time.Millisecond},
+		{name: "MP3 MPEG-1", content: mp3, format: MP3, want: 432 * time.Millisecond},
+		{name: "MP3 MPEG-2.5", content: readFixture(t, "clip_mpeg25.mp3"), format: MP3, want: 5256 * time.Millisecond},
+		{name: "MP3 with an ID3 tag", content: append([]byte("ID3\x04\x00\x00\x00\x00\x00\x05hello"), mp3...), format: MP3, want: 432 * time.Millisecond},
+		{name: "Ogg Vorbis", content: ogg, format: Ogg, want: 9920 * time.Second / 44100},
+		{name: "M4A", content: m4a, format: MP4, want: 13812 * time.Millisecond},
+		{name: "MP4 version 1 header", content: mp4WithDuration(1, 48000, 48000*12), format: MP4, want: 12 * time.Second},
+		// duration*time.Second alone wraps around to about 10s here
+		{name: "MP4 duration that overflows when scaled first", content: mp4WithDuration(1, 1000, 18446754074), format: MP4, want: 18446754074 * time.Millisecond},
+		{name: "Opus", content: opusStream(312, 48000, 48000*5+312), format: Ogg, want: 5 * time.Second},
+		{name: "Opus with an unfinished packet on the last page", content: append(opusStream(312, 48000*5+312), oggPageBytes(0, oggNoGranule, 7, 3, []byte("x"))...), format: Ogg, want: 5 * time.Second},
+		{name: "Ogg capture pattern inside packet data", content: oggCapture, format: Ogg, want: 9920 * time.Second / 44100},
+		{name: "Ogg other streams are ignored", content: muxed, format: Ogg, want: time.Second},
+
+		// Truncated files
+		{name: "WAV header only", content: wav[:20], format: WAV, wantErr: true},
+		{name: "WAV cut short", content: wav[:2044], format: WAV, want: 250 * time.Millisecond},
+		{name: "MP3 cut mid-frame", content: mp3[:6000], format: MP3, want: 180 * time.Millisecond},
+		{name: "MP3 header only", content: mp3[:4], format: MP3, wantErr: true},
+		{name: "Ogg cut mid-page", content: ogg[:len(ogg)-1], format: Ogg, wantErr: true},
+		{name: "Ogg first page only", content: ogg[:58], format: Ogg, wantErr: true},
+		{name: "M4A without its movie header", content: m4a[:40], format: MP4, wantErr: true},
+		{name: "Empty", content: nil, format: MP3, wantErr: true},
+
+		// Malformed files
+		{name: "MP3 of junk", content: bytes.Repeat([]byte("not audio "), 100), format: MP3, wantErr: true},
+		{name: "MP3 ID3 tag longer than the file", content: []byte("ID3\x04\x00\x00\x7f\x7f\x7f\x7f"), format: MP3, wantErr: true},
+		{name: "WAV without a format chunk", content: append([]byte("RIFF\x00\x00\x00\x00WAVE"), wav[36:]...), format: WAV, wantErr: true},
+		{name: "WAV with a zero byte rate", content: append(append(append([]byte{}, wav[:28]...), 0, 0, 0, 0), wav[32:]...), format: WAV, wantErr: true},
+		{name: "Not a WAV", content: append([]byte("RIFF\x00\x00\x00\x00AVI "), wav[12:]...), format: WAV, wantErr: true},
+		{name: "Ogg with a bad checksum", content: oggCorrupt, format: Ogg, wantErr: true},
+		{name: "Ogg without a first page flag", content: append(oggPageBytes(0, 0, 7, 0, []byte("OpusHead\x01\x01\x38\x01\x80\xbb\x00\x00\x00\x00\x00")), opusStream(0, 48000)[47:]...), format: Ogg, wantErr: true},
+		{name: "Ogg Speex", content: append(oggPageBytes(oggFirstPage, 0, 7, 0, []byte("Speex   1.2rc1\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")), oggPageBytes(0, 32000, 7, 1, []byte("x"))...), format: Ogg, wantErr: true},
+		{name: "Ogg ending before the pre-skip", content: opusStream(312, 300), format: Ogg, wantErr: true},
+		{name: "Ogg followed by junk", content: append(append([]byte{}, ogg...), "junk"...), format: Ogg, wantErr: true},
+		{name: "MP4 with a zero timescale", content: mp4WithDuration(0, 0, 1000), format: MP4, wantErr: true},
+		{name: "MP4 of unknown duration", content: mp4WithDuration(0, 1000, 0xffffffff), format: MP4, wantErr: true},
+		{name: "MP4 with a box larger than the file", content: append([]byte("\x00\x00\xff\xffmoov"), m4a[8:100]...), format: MP4, wantErr: true},
+		{name: "MP4 duration too long for time.Duration", content: mp4WithDuration(1, 1000, 1<<60), format: MP4, wantErr: true},
+		{name: "Unknown format", content: wav, format: Format(42), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Duration(tt.content, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Duration() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Duration() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTicksToDuration(t *testing.T) {
+	tests := []struct {
+		ticks, rate uint64
+		want        time.Duration
+		wantErr     bool
+	}{
+		{ticks: 48000, rate: 48000, want: time.Second},
+		{ticks: 1, rate: 3, want: 333333333},
+		{ticks: 0, rate: 1000, want: 0},
+		{ticks: 1 << 62, rate: 1 << 40, want: time.Duration((1 << 22) * uint64(time.Second))},
+		{ticks: 9223372036, rate: 1, want: 9223372036 * time.Second},
+		{ticks: 9223372037, rate: 1, wantErr: true}, // Just past the longest time.Duration
+		{ticks: 1 << 63, rate: 1, wantErr: true},
+		{ticks: 18446754074, rate: 1000, want: 18446754074 * time.Millisecond},
+		{ticks: 1, rate: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ticksToDuration(tt.ticks, tt.rate)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ticksToDuration(%d, %d) = %v, %v, want %v, error %v", tt.ticks, tt.rate, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AttachmentKind is what an attachment holds, decided from its sniffed MIME type.
+type AttachmentKind string
+
+const (
+	AttachmentImage AttachmentKind = "image"
+	AttachmentAudio AttachmentKind = "audio" // Voice notes
+)
+
+var (
+	ErrPhotosLocked       = errors.New("photos are not unlocked in this conversation yet")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// Attachment is a file sent with a message. The file itself lives in a blob store;
+// clients download it through a short-lived signed URL.
+type Attachment struct {
+	ID         string         `json:"id"`
+	Kind       AttachmentKind `json:"kind"`
+	MimeType   string         `json:"mime_type"`
+	SizeBytes  int64          `json:"size_bytes"`
+	StorageKey string         `json:"-"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// insertAttachment links an uploaded file to a freshly inserted message, filling in its ID.
+func insertAttachment(tx *sql.Tx, messageID string, att *Attachment) error {
+	query := `
+		INSERT INTO attachments (message_id, kind, mime_type, size_bytes, storage_key)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	return tx.QueryRow(query, messageID, att.Kind, att.MimeType, att.SizeBytes, att.StorageKey).Scan(&att.ID, &att.CreatedAt)
+}
+
+// deleteConversationAttachments removes the attachments of every message in a conversation
+// and returns their storage keys, so that the files can be deleted too.
+func deleteConversationAttachments(tx *sql.Tx, conversationID string) ([]string, error) {
+	query := `
+		DELETE FROM attachments a
+		USING messages m
+		WHERE a.message_id = m.id AND m.conversation_id = $1
+		RETURNING a.storage_key`
+
+	rows, err := tx.Query(query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAttachment returns an attachment, provided the user takes part in its conversation
+// and the message was not deleted. Once the conversation is blocked it returns ErrConversationBlocked.
+func (m ConversationModel) GetAttachment(attachmentID, userID string) (*Attachment, error) {
+	if !isUUID(attachmentID) {
+		return nil, ErrAttachmentNotFound
+	}
+	query := `
+		SELECT a.id, a.kind, a.mime_type, a.size_bytes, a.storage_key, a.created_at, c.status
+		FROM attachments a
+		JOIN messages m ON m.id = a.message_id
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE a.id = $1::uuid
+			AND (c.user_a_id = $2 OR c.user_b_id = $2)
+			AND m.deleted_at IS NULL`
+
+	var att Attachment
+	var status ConversationStatus
+	err := m.DB.QueryRow(query, attachmentID, userID).Scan(&att.ID, &att.Kind, &att.MimeType, &att.SizeBytes, &att.StorageKey, &att.CreatedAt, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	// A block cuts off access to the media shared before it
+	if status == StatusBlocked {
+		return nil, ErrConversationBlocked
+	}
+	return &att, nil
+}
+
+// attachAttachments fills in the attachments of the given messages with a single query.
+func attachAttachments(q messageQueryer, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(messages))
+	byID := make(map[string]*Message, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+		byID[messages[i].ID] = &messages[i]
+	}
+
+	query := `
+		SELECT message_id, id, kind, mime_type, size_bytes, storage_key, created_at
+		FROM attachments
+		WHERE message_id = ANY($1::uuid[])`
+
+	rows, err := q.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var att Attachment
+		if err := rows.Scan(&messageID, &att.ID, &att.Kind, &att.MimeType, &att.SizeBytes, &att.StorageKey, &att.CreatedAt); err != nil {
+			return err
+		}
+		if msg, ok := byID[messageID]; ok {
+			msg.Attachment = &att
+		}
+	}
+	return rows.Err()
+}
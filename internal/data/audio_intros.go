@@ -0,0 +1,88 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrAudioIntroNotFound = errors.New("user has no audio intro")
+
+// AudioIntro is the short voice clip on a user's profile. The file lives in a blob store.
+type AudioIntro struct {
+	MimeType   string    `json:"mime_type"`
+	SizeBytes  int64     `json:"size_bytes"`
+	DurationMs int       `json:"duration_ms"`
+	StorageKey string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SetAudioIntro stores the user's audio intro, replacing any previous one.
+// The storage key of the replaced clip is returned so the caller can remove its file.
+func (m ProfileModel) SetAudioIntro(userID string, intro *AudioIntro) (replacedBlob string, err error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// 1. Remember the clip being replaced
+	err = tx.QueryRow(`SELECT storage_key FROM audio_intros WHERE user_id = $1 FOR UPDATE`, userID).Scan(&replacedBlob)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	// 2. Store the new one
+	upsertQuery := `
+		INSERT INTO audio_intros (user_id, storage_key, mime_type, size_bytes, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			storage_key = EXCLUDED.storage_key,
+			mime_type = EXCLUDED.mime_type,
+			size_bytes = EXCLUDED.size_bytes,
+			duration_ms = EXCLUDED.duration_ms,
+			created_at = NOW()
+		RETURNING created_at`
+	err = tx.QueryRow(upsertQuery, userID, intro.StorageKey, intro.MimeType, intro.SizeBytes, intro.DurationMs).Scan(&intro.CreatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return replacedBlob, nil
+}
+
+// GetAudioIntro returns the user's audio intro, or ErrAudioIntroNotFound.
+func (m ProfileModel) GetAudioIntro(userID string) (*AudioIntro, error) {
+	query := `SELECT storage_key, mime_type, size_bytes, duration_ms, created_at FROM audio_intros WHERE user_id = $1`
+
+	var intro AudioIntro
+	err := m.DB.QueryRow(query, userID).Scan(&intro.StorageKey, &intro.MimeType, &intro.SizeBytes, &intro.DurationMs, &intro.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAudioIntroNotFound
+		}
+		return nil, err
+	}
+	return &intro, nil
+}
+
+// DeleteAudioIntro removes the user's audio intro, returning it so the caller can remove its file.
+func (m ProfileModel) DeleteAudioIntro(userID string) (*AudioIntro, error) {
+	query := `
+		DELETE FROM audio_intros
+		WHERE user_id = $1
+		RETURNING storage_key, mime_type, size_bytes, duration_ms, created_at`
+
+	var intro AudioIntro
+	err := m.DB.QueryRow(query, userID).Scan(&intro.StorageKey, &intro.MimeType, &intro.SizeBytes, &intro.DurationMs, &intro.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAudioIntroNotFound
+		}
+		return nil, err
+	}
+	return &intro, nil
+}
@@ -10,13 +10,16 @@ type ConversationStatus string
 
 type ConversationDetails struct {
 	Conversation
-	Messages []Message `json:"messages"`
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"` // Cursor for older messages, see GetMessages
 }
 
 const (
-	StatusPending ConversationStatus = "pending"
-	StatusActive  ConversationStatus = "active"
-	StatusBlocked ConversationStatus = "blocked"
+	StatusPending  ConversationStatus = "pending"
+	StatusActive   ConversationStatus = "active"
+	StatusBlocked  ConversationStatus = "blocked"
+	StatusDeclined ConversationStatus = "declined"
+	StatusExpired  ConversationStatus = "expired"
 )
 
 type Conversation struct {
@@ -27,18 +30,25 @@ type Conversation struct {
 	MessageCount   int                `json:"message_count"`
 	PhotosUnlocked bool               `json:"photos_unlocked"`
 	NamesUnlocked  bool               `json:"names_unlocked"`
-	CreatedAt      time.Time          `json:"created_at"`
-	UpdatedAt      time.Time          `json:"updated_at"`
+	// How many messages each participant must send before names/photos are revealed
+	NamesUnlockThreshold  int       `json:"names_unlock_threshold"`
+	PhotosUnlockThreshold int       `json:"photos_unlock_threshold"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 type ConversationPreview struct {
 	ConversationID    string             `json:"conversation_id"`
 	Status            ConversationStatus `json:"status"`
 	OtherUserID       string             `json:"other_user_id"`
-	OtherUserDisplay  string             `json:"other_user_display_name"`
+	OtherUserDisplay  string             `json:"other_user_display_name"` // Pseudonym until names are unlocked
+	NamesUnlocked     bool               `json:"names_unlocked"`
 	LastMessage       string             `json:"last_message"`
 	LastMessageSender string             `json:"last_message_sender_id"`
 	LastMessageAt     time.Time          `json:"last_message_at"`
+	UnreadCount       int                `json:"unread_count"` // Messages from the other user after the last one read
+	OtherUserOnline   bool               `json:"other_user_online"`
+	LastSeenAt        *time.Time         `json:"last_seen_at"` // Null if the other user hides it, was never seen or the conversation is blocked
 	UpdatedAt         time.Time          `json:"updated_at"`
 }
 
@@ -49,17 +59,39 @@ type Message struct {
 	Content          string    `json:"content"`
 	IsOpeningMessage bool      `json:"is_opening_message"`
 	CreatedAt        time.Time `json:"created_at"`
+	// Set once the sender edits the message
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// Set once the sender deletes the message. Its content is erased and only this tombstone remains.
+	DeletedAt  *time.Time  `json:"deleted_at,omitempty"`
+	Reactions  []Reaction  `json:"reactions,omitempty"`
+	Attachment *Attachment `json:"attachment,omitempty"`
 }
 
+var (
+	ErrConversationBlocked    = errors.New("conversation is blocked")
+	ErrConversationNotBlocked = errors.New("conversation is not blocked by this user")
+	ErrNotParticipant         = errors.New("conversation not found or user is not a participant")
+	ErrConversationClosed     = errors.New("conversation request was declined or has expired")
+	ErrNotPending             = errors.New("conversation is not a pending request")
+	ErrNotRecipient           = errors.New("only the recipient can decline a conversation request")
+	ErrAwaitingReply          = errors.New("cannot send another message until the recipient replies")
+	ErrInvalidConversationID  = errors.New("invalid conversation id")
+)
+
 type ConversationModel struct {
 	DB *sql.DB
+	// ReactionsAcceptRequests makes the recipient's reaction to a pending request accept it,
+	// like a reply would. Off by default, so a reaction stays a low-pressure gesture.
+	ReactionsAcceptRequests bool
 }
 
 // Start initiates a new conversation with the first message.
-func (m ConversationModel) Start(senderID, recipientID, content string) (*Conversation, error) {
+// When it recycles an expired request it returns the storage keys of the files that were
+// sent in it, for the caller to delete from the blob store.
+func (m ConversationModel) Start(senderID, recipientID, content string) (conv *Conversation, removedBlobs []string, err error) {
 	tx, err := m.DB.Begin()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer tx.Rollback()
 
@@ -72,27 +104,50 @@ func (m ConversationModel) Start(senderID, recipientID, content string) (*Conver
 		userB = senderID
 	}
 
-	// 1. Create the conversation record
+	// 1. Create the conversation record.
+	// An expired request is recycled so the sender can try again.
 	convQuery := `
 		INSERT INTO conversations (user_a_id, user_b_id)
 		VALUES ($1, $2)
-		ON CONFLICT (user_a_id, user_b_id) DO NOTHING
-		RETURNING id, status, created_at, updated_at`
-
-	var conv Conversation
-	err = tx.QueryRow(convQuery, userA, userB).Scan(&conv.ID, &conv.Status, &conv.CreatedAt, &conv.UpdatedAt)
+		ON CONFLICT (user_a_id, user_b_id) DO UPDATE SET
+			status = 'pending',
+			message_count = 0,
+			names_unlocked = FALSE,
+			photos_unlocked = FALSE,
+			blocked_by = NULL,
+			status_before_block = NULL,
+			created_at = NOW(),
+			updated_at = NOW()
+		WHERE conversations.status = 'expired'
+		RETURNING id, status, names_unlock_threshold, photos_unlock_threshold, created_at, updated_at`
+
+	conv = &Conversation{}
+	err = tx.QueryRow(convQuery, userA, userB).Scan(&conv.ID, &conv.Status, &conv.NamesUnlockThreshold, &conv.PhotosUnlockThreshold, &conv.CreatedAt, &conv.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// This means a conversation already exists, which is an edge case we can handle.
 			// For now, we'll treat it as an error to keep it simple.
-			return nil, errors.New("conversation already exists or could not be created")
+			return nil, nil, errors.New("conversation already exists or could not be created")
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
 	conv.UserAID = userA
 	conv.UserBID = userB
 
+	// Drop what is left of a recycled request: the files sent in it, the read markers
+	// and the unanswered opening message
+	removedBlobs, err = deleteConversationAttachments(tx, conv.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM conversation_reads WHERE conversation_id = $1`, conv.ID); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE conversation_id = $1`, conv.ID); err != nil {
+		return nil, nil, err
+	}
+
 	// 2. Insert the first message
 	msgQuery := `
 		INSERT INTO messages (conversation_id, sender_id, content, is_opening_message)
@@ -102,15 +157,22 @@ func (m ConversationModel) Start(senderID, recipientID, content string) (*Conver
 	var msgID string
 	err = tx.QueryRow(msgQuery, conv.ID, senderID, content).Scan(&msgID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// 3. Commit the transaction
+	// 3. Count the opening message towards the reveal
+	reveal, err := advanceReveal(tx, conv.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	conv.MessageCount = reveal.MessageCount
+
+	// 4. Commit the transaction
 	if err := tx.Commit(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return &conv, nil
+	return conv, removedBlobs, nil
 }
 
 func (m ConversationModel) GetAllForUser(userID string) ([]ConversationPreview, error) {
@@ -118,11 +180,31 @@ func (m ConversationModel) GetAllForUser(userID string) ([]ConversationPreview,
 		SELECT
 			c.id AS conversation_id,
 			c.status,
+			c.names_unlocked,
 			other_user.id AS other_user_id,
 			other_user.display_name AS other_user_display_name,
 			last_msg.content AS last_message,
 			last_msg.sender_id AS last_message_sender_id,
 			last_msg.created_at AS last_message_at,
+			(
+				SELECT COUNT(*)
+				FROM messages um
+				WHERE um.conversation_id = c.id
+					AND um.sender_id != $1
+					AND um.deleted_at IS NULL
+					AND (cr.last_read_at IS NULL OR um.created_at > cr.last_read_at)
+			) AS unread_count,
+			-- Presence is only shared in open conversations, like PresenceModel.Partners does for live updates
+			c.status IN ('pending', 'active') AND NOT other_user.hide_last_seen AND EXISTS (
+				SELECT 1
+				FROM presence_sessions ps
+				WHERE ps.user_id = other_user.id
+					AND ps.heartbeat_at > NOW() - $2 * INTERVAL '1 second'
+			) AS other_user_online,
+			CASE
+				WHEN c.status NOT IN ('pending', 'active') OR other_user.hide_last_seen THEN NULL
+				ELSE other_user.last_seen_at
+			END AS last_seen_at,
 			c.updated_at
 		FROM
 			conversations c
@@ -144,13 +226,15 @@ func (m ConversationModel) GetAllForUser(userID string) ([]ConversationPreview,
 				m.created_at DESC
 			LIMIT 1
 		) last_msg ON TRUE
+		LEFT JOIN
+			conversation_reads cr ON cr.conversation_id = c.id AND cr.user_id = $1
 		WHERE
 			c.user_a_id = $1 OR c.user_b_id = $1
 		ORDER BY
 			c.updated_at DESC;
 	`
 
-	rows, err := m.DB.Query(query, userID)
+	rows, err := m.DB.Query(query, userID, int64(PresenceStaleAfter.Seconds()))
 	if err != nil {
 		return nil, err
 	}
@@ -159,23 +243,29 @@ func (m ConversationModel) GetAllForUser(userID string) ([]ConversationPreview,
 	var previews []ConversationPreview
 	for rows.Next() {
 		var p ConversationPreview
-		var lastMsg, lastMsgSender sql.NullString
-		var lastMsgAt sql.NullTime
+		var otherUserDisplay, lastMsg, lastMsgSender sql.NullString
+		var lastMsgAt, lastSeenAt sql.NullTime
 
 		err := rows.Scan(
 			&p.ConversationID,
 			&p.Status,
+			&p.NamesUnlocked,
 			&p.OtherUserID,
-			&p.OtherUserDisplay,
+			&otherUserDisplay,
 			&lastMsg,
 			&lastMsgSender,
 			&lastMsgAt,
+			&p.UnreadCount,
+			&p.OtherUserOnline,
+			&lastSeenAt,
 			&p.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		// Never leak the other user's real name before the reveal
+		p.OtherUserDisplay = VisibleName(p.OtherUserID, otherUserDisplay.String, p.NamesUnlocked)
 		if lastMsg.Valid {
 			p.LastMessage = lastMsg.String
 		}
@@ -185,34 +275,58 @@ func (m ConversationModel) GetAllForUser(userID string) ([]ConversationPreview,
 		if lastMsgAt.Valid {
 			p.LastMessageAt = lastMsgAt.Time
 		}
+		if lastSeenAt.Valid {
+			p.LastSeenAt = &lastSeenAt.Time
+		}
 		previews = append(previews, p)
 	}
 
 	return previews, nil
 }
 
-func (m ConversationModel) AddMessage(conversationID, senderID, content string) (*Message, error) {
+// AddMessage stores a new message and advances the conversation's progressive reveal.
+// The returned RevealState lists any features this message unlocked.
+func (m ConversationModel) AddMessage(conversationID, senderID, content string) (*Message, *RevealState, error) {
+	return m.AddMessageWithAttachment(conversationID, senderID, content, nil)
+}
+
+// AddMessageWithAttachment is AddMessage for a message carrying an already uploaded file.
+// content is an optional caption. Images are refused with ErrPhotosLocked until photos are unlocked.
+func (m ConversationModel) AddMessageWithAttachment(conversationID, senderID, content string, att *Attachment) (*Message, *RevealState, error) {
 	tx, err := m.DB.Begin()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer tx.Rollback()
 
-	// 1. Get current conversation status and participants
+	// 1. Lock the conversation and get its status and participants.
+	// The lock keeps a concurrent block, decline or expiry from being overwritten below.
 	var status ConversationStatus
 	var userA, userB string
-	convQuery := `SELECT status, user_a_id, user_b_id FROM conversations WHERE id = $1`
-	err = tx.QueryRow(convQuery, conversationID).Scan(&status, &userA, &userB)
+	var photosUnlocked bool
+	convQuery := `SELECT status, user_a_id, user_b_id, photos_unlocked FROM conversations WHERE id = $1 FOR UPDATE`
+	err = tx.QueryRow(convQuery, conversationID).Scan(&status, &userA, &userB, &photosUnlocked)
 	if err != nil {
-		return nil, errors.New("conversation not found")
+		return nil, nil, errors.New("conversation not found")
 	}
 
 	// 2. Check if the sender is part of this conversation
 	if senderID != userA && senderID != userB {
-		return nil, errors.New("user is not part of this conversation")
+		return nil, nil, errors.New("user is not part of this conversation")
 	}
 
-	// 3. Logic to activate a pending conversation
+	// 3. Nobody can send into a blocked, declined or expired conversation
+	if status == StatusBlocked {
+		return nil, nil, ErrConversationBlocked
+	}
+	if status == StatusDeclined || status == StatusExpired {
+		return nil, nil, ErrConversationClosed
+	}
+	if att != nil && att.Kind == AttachmentImage && !photosUnlocked {
+		return nil, nil, ErrPhotosLocked
+	}
+
+	// 4. Logic to activate a pending conversation
 	if status == StatusPending {
 		// Find out who sent the first message
 		var firstMessageSenderID string
@@ -220,13 +334,13 @@ func (m ConversationModel) AddMessage(conversationID, senderID, content string)
 		err = tx.QueryRow(firstMsgQuery, conversationID).Scan(&firstMessageSenderID)
 		if err != nil {
 			// This should theoretically not happen if the conversation exists
-			return nil, errors.New("could not find opening message for pending conversation")
+			return nil, nil, errors.New("could not find opening message for pending conversation")
 		}
 
 		// *** NEW RULE ENFORCEMENT ***
 		// If the current sender IS the one who sent the first message, they cannot send another.
 		if senderID == firstMessageSenderID {
-			return nil, errors.New("cannot send another message until the recipient replies")
+			return nil, nil, ErrAwaitingReply
 		}
 
 		// If we reach here, it means the current sender is the RECIPIENT.
@@ -234,11 +348,11 @@ func (m ConversationModel) AddMessage(conversationID, senderID, content string)
 		updateStatusQuery := `UPDATE conversations SET status = 'active' WHERE id = $1`
 		_, err = tx.Exec(updateStatusQuery, conversationID)
 		if err != nil {
-			return nil, errors.New("failed to activate conversation")
+			return nil, nil, errors.New("failed to activate conversation")
 		}
 	}
 
-	// 4. Insert the new message
+	// 5. Insert the new message
 	msgQuery := `
 		INSERT INTO messages (conversation_id, sender_id, content)
 		VALUES ($1, $2, $3)
@@ -251,14 +365,27 @@ func (m ConversationModel) AddMessage(conversationID, senderID, content string)
 
 	err = tx.QueryRow(msgQuery, conversationID, senderID, content).Scan(&msg.ID, &msg.CreatedAt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// 5. Commit
+	if att != nil {
+		if err := insertAttachment(tx, msg.ID, att); err != nil {
+			return nil, nil, err
+		}
+		msg.Attachment = att
+	}
+
+	// 6. Advance the progressive reveal
+	reveal, err := advanceReveal(tx, conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 7. Commit
 	if err := tx.Commit(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return &msg, nil
+	return &msg, reveal, nil
 }
 
 func (m ConversationModel) GetByID(conversationID, userID string) (*ConversationDetails, error) {
@@ -271,41 +398,31 @@ func (m ConversationModel) GetByID(conversationID, userID string) (*Conversation
 	// 1. Get conversation details and verify the user is a participant
 	var conv Conversation
 	convQuery := `
-		SELECT id, user_a_id, user_b_id, status, message_count, photos_unlocked, names_unlocked, created_at, updated_at
+		SELECT id, user_a_id, user_b_id, status, message_count, photos_unlocked, names_unlocked,
+			names_unlock_threshold, photos_unlock_threshold, created_at, updated_at
 		FROM conversations
 		WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2)`
 
 	err = tx.QueryRow(convQuery, conversationID, userID).Scan(
-		&conv.ID, &conv.UserAID, &conv.UserBID, &conv.Status, &conv.MessageCount, &conv.PhotosUnlocked, &conv.NamesUnlocked, &conv.CreatedAt, &conv.UpdatedAt,
+		&conv.ID, &conv.UserAID, &conv.UserBID, &conv.Status, &conv.MessageCount, &conv.PhotosUnlocked, &conv.NamesUnlocked,
+		&conv.NamesUnlockThreshold, &conv.PhotosUnlockThreshold, &conv.CreatedAt, &conv.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("conversation not found or user is not a participant")
+			return nil, ErrNotParticipant
 		}
 		return nil, err
 	}
 
-	// 2. Get the last 50 messages for this conversation
-	msgQuery := `
-		SELECT id, conversation_id, sender_id, content, is_opening_message, created_at
-		FROM messages
-		WHERE conversation_id = $1
-		ORDER BY created_at ASC
-		LIMIT 50`
-
-	rows, err := tx.Query(msgQuery, conversationID)
+	// 2. Get the newest page of messages, returned oldest first for display.
+	// Older history is fetched through GetMessages with NextCursor.
+	page, err := listMessages(tx, conversationID, "", DefaultMessagePageSize)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var messages []Message
-	for rows.Next() {
-		var msg Message
-		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.IsOpeningMessage, &msg.CreatedAt); err != nil {
-			return nil, err
-		}
-		messages = append(messages, msg)
+	messages := page.Messages
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -315,7 +432,190 @@ func (m ConversationModel) GetByID(conversationID, userID string) (*Conversation
 	details := &ConversationDetails{
 		Conversation: conv,
 		Messages:     messages,
+		NextCursor:   page.NextCursor,
 	}
 
 	return details, nil
 }
+
+// Participants returns the IDs of both users in a conversation.
+func (m ConversationModel) Participants(conversationID string) ([]string, error) {
+	var userA, userB string
+	query := `SELECT user_a_id, user_b_id FROM conversations WHERE id = $1`
+	if err := m.DB.QueryRow(query, conversationID).Scan(&userA, &userB); err != nil {
+		return nil, err
+	}
+	return []string{userA, userB}, nil
+}
+
+// StatusFor returns the status of a conversation the user takes part in. It returns
+// ErrInvalidConversationID if the ID is not a UUID and ErrNotParticipant if there is no
+// such conversation or the user is not part of it.
+func (m ConversationModel) StatusFor(conversationID, userID string) (ConversationStatus, error) {
+	if !isUUID(conversationID) {
+		return "", ErrInvalidConversationID
+	}
+	var status ConversationStatus
+	query := `SELECT status FROM conversations WHERE id = $1::uuid AND (user_a_id = $2 OR user_b_id = $2)`
+	if err := m.DB.QueryRow(query, conversationID, userID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotParticipant
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// Block marks a conversation as blocked by one of its participants.
+func (m ConversationModel) Block(conversationID, userID string) error {
+	query := `
+		UPDATE conversations
+		SET status = 'blocked', blocked_by = $2, status_before_block = status
+		WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2) AND status != 'blocked'`
+
+	result, err := m.DB.Exec(query, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// Either the user is not a participant or the conversation is already blocked
+		var status ConversationStatus
+		checkQuery := `SELECT status FROM conversations WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2)`
+		if err := m.DB.QueryRow(checkQuery, conversationID, userID).Scan(&status); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotParticipant
+			}
+			return err
+		}
+		return ErrConversationBlocked
+	}
+	return nil
+}
+
+// Unblock lifts a block and returns the conversation's new status. Only the user who blocked
+// the conversation can unblock it.
+// The conversation goes back to the status it had when it was blocked, so a declined or
+// expired request stays closed. Blocks from before that status was kept fall back to
+// active if both sides have already talked, otherwise to pending.
+func (m ConversationModel) Unblock(conversationID, userID string) (ConversationStatus, error) {
+	query := `
+		UPDATE conversations
+		SET
+			status = COALESCE(status_before_block, CASE
+				WHEN (SELECT COUNT(DISTINCT sender_id) FROM messages WHERE conversation_id = $1) > 1
+				THEN 'active'::conversation_status
+				ELSE 'pending'::conversation_status
+			END),
+			blocked_by = NULL,
+			status_before_block = NULL
+		WHERE id = $1 AND status = 'blocked' AND blocked_by = $2
+		RETURNING status`
+
+	var status ConversationStatus
+	if err := m.DB.QueryRow(query, conversationID, userID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrConversationNotBlocked
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// Decline lets the recipient of a pending conversation request turn it down.
+func (m ConversationModel) Decline(conversationID, userID string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. Lock the conversation and verify the user is a participant
+	var status ConversationStatus
+	convQuery := `
+		SELECT status FROM conversations
+		WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2)
+		FOR UPDATE`
+	err = tx.QueryRow(convQuery, conversationID, userID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotParticipant
+		}
+		return err
+	}
+	if status != StatusPending {
+		return ErrNotPending
+	}
+
+	// 2. The sender of the opening message cannot decline their own request
+	var openingSenderID string
+	openingQuery := `SELECT sender_id FROM messages WHERE conversation_id = $1 AND is_opening_message LIMIT 1`
+	if err := tx.QueryRow(openingQuery, conversationID).Scan(&openingSenderID); err != nil {
+		return err
+	}
+	if openingSenderID == userID {
+		return ErrNotRecipient
+	}
+
+	// 3. Decline it
+	if _, err := tx.Exec(`UPDATE conversations SET status = 'declined' WHERE id = $1`, conversationID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ExpiredRequest is a pending conversation request that timed out.
+type ExpiredRequest struct {
+	ConversationID string `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	RecipientID    string `json:"recipient_id"`
+}
+
+// ExpirePending marks every pending request older than ttl as expired and returns them,
+// so that the senders can be notified.
+func (m ConversationModel) ExpirePending(ttl time.Duration) ([]ExpiredRequest, error) {
+	query := `
+		UPDATE conversations c
+		SET status = 'expired'
+		WHERE c.status = 'pending' AND c.created_at < NOW() - $1 * INTERVAL '1 second'
+		RETURNING
+			c.id,
+			c.user_a_id,
+			c.user_b_id,
+			(
+				SELECT m.sender_id
+				FROM messages m
+				WHERE m.conversation_id = c.id AND m.is_opening_message
+				LIMIT 1
+			)`
+
+	rows, err := m.DB.Query(query, int64(ttl.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expired []ExpiredRequest
+	for rows.Next() {
+		var req ExpiredRequest
+		var userA, userB string
+		var senderID sql.NullString
+		if err := rows.Scan(&req.ConversationID, &userA, &userB, &senderID); err != nil {
+			return nil, err
+		}
+		req.SenderID = senderID.String
+		req.RecipientID = userA
+		if req.SenderID == userA {
+			req.RecipientID = userB
+		}
+		expired = append(expired, req)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAddMessageRefusesClosedConversation(t *testing.T) {
+	// The status is read under FOR UPDATE, so it is whatever a block, decline or expiry
+	// committed while the send waited for the lock.
+	tests := []struct {
+		name    string
+		status  ConversationStatus
+		wantErr error
+	}{
+		{name: "blocked", status: StatusBlocked, wantErr: ErrConversationBlocked},
+		{name: "declined", status: StatusDeclined, wantErr: ErrConversationClosed},
+		{name: "expired", status: StatusExpired, wantErr: ErrConversationClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t,
+				row("FOR UPDATE", string(tt.status), "user-a", "user-b", false),
+			)
+			model := ConversationModel{DB: db}
+
+			_, _, err := model.AddMessage("conv-1", "user-b", "hello")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if fake.ran("UPDATE") || fake.ran("INSERT") || fake.ran("COMMIT") {
+				t.Errorf("AddMessage() changed a closed conversation: %q", fake.log)
+			}
+		})
+	}
+}
+
+func TestStartRecyclesExpiredRequest(t *testing.T) {
+	now := time.Now()
+	db, fake := newFakeDB(t,
+		row("INSERT INTO conversations", "conv-1", "pending", int64(5), int64(10), now, now),
+		fakeStep{
+			match:   "DELETE FROM attachments",
+			columns: []string{"storage_key"},
+			rows:    [][]driver.Value{{"attachments/one"}, {"attachments/two"}},
+		},
+		affected("DELETE FROM conversation_reads", 2),
+		affected("DELETE FROM messages", 3),
+		row("INSERT INTO messages", "msg-1"),
+		row("UPDATE conversations", "user-a", "user-b", int64(1), false, false, int64(5), int64(10)),
+		row("COUNT(*)", int64(1), int64(0)),
+	)
+	model := ConversationModel{DB: db}
+
+	conv, removedBlobs, err := model.Start("user-a", "user-b", "hello again")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if conv.ID != "conv-1" || conv.MessageCount != 1 {
+		t.Errorf("Start() = %+v, want conversation conv-1 with 1 message", conv)
+	}
+	if want := []string{"attachments/one", "attachments/two"}; !slices.Equal(removedBlobs, want) {
+		t.Errorf("Start() removed blobs %q, want %q", removedBlobs, want)
+	}
+	if !fake.ran("COMMIT") {
+		t.Error("Start() did not commit")
+	}
+}
+
+func TestStatusFor(t *testing.T) {
+	const conversationID = "3f1c2b4a-8d7e-4c6b-9a5f-0e1d2c3b4a59"
+
+	t.Run("malformed id", func(t *testing.T) {
+		db, _ := newFakeDB(t)
+		if _, err := (ConversationModel{DB: db}).StatusFor("not-a-uuid", "user-a"); !errors.Is(err, ErrInvalidConversationID) {
+			t.Errorf("StatusFor() error = %v, want ErrInvalidConversationID", err)
+		}
+	})
+
+	t.Run("not a participant", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeStep{match: "SELECT status FROM conversations", columns: []string{"status"}})
+		if _, err := (ConversationModel{DB: db}).StatusFor(conversationID, "user-c"); !errors.Is(err, ErrNotParticipant) {
+			t.Errorf("StatusFor() error = %v, want ErrNotParticipant", err)
+		}
+	})
+
+	t.Run("participant", func(t *testing.T) {
+		db, _ := newFakeDB(t, row("SELECT status FROM conversations", string(StatusBlocked)))
+		status, err := (ConversationModel{DB: db}).StatusFor(conversationID, "user-a")
+		if err != nil || status != StatusBlocked {
+			t.Errorf("StatusFor() = %q, %v, want %q", status, err, StatusBlocked)
+		}
+	})
+}
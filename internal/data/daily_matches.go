@@ -0,0 +1,251 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	// DefaultDailyBatchSize is how many people are suggested per day when MatchModel.DailyBatchSize is unset
+	DefaultDailyBatchSize = 10
+	// How many days of old batches are kept
+	dailyBatchRetentionDays = 7
+)
+
+// BatchDay returns the day a batch built at t belongs to. Days start at midnight UTC.
+func BatchDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func (m MatchModel) dailyBatchSize() int {
+	if m.DailyBatchSize <= 0 {
+		return DefaultDailyBatchSize
+	}
+	return min(m.DailyBatchSize, MaxMatchPageSize)
+}
+
+// BuildDailyBatch picks the user's suggestions for the day with the full matching query,
+// unless they were already picked. built is false if there was nothing to do. A batch is
+// stored even when nobody matches, so the query runs at most once per user and day.
+func (m MatchModel) BuildDailyBatch(userID string, day time.Time) (built bool, err error) {
+	day = BatchDay(day)
+
+	// 1. Skip the heavy query if the batch exists
+	var exists bool
+	existsQuery := `SELECT EXISTS(SELECT 1 FROM daily_match_batches WHERE user_id = $1 AND batch_date = $2)`
+	if err := m.DB.QueryRow(existsQuery, userID, day).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	// 2. Pick the best matches right now
+	matches, err := m.GetPotentialMatches(userID, m.dailyBatchSize())
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 3. Claim the batch. Whoever claims it second, e.g. the job racing a request, backs off.
+	result, err := tx.Exec(`INSERT INTO daily_match_batches (user_id, batch_date) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, day)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	// 4. Store the suggestions in order
+	insertQuery := `
+		INSERT INTO daily_matches (user_id, batch_date, position, match_user_id, score, match_reason)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	for position, match := range matches {
+		if _, err := tx.Exec(insertQuery, userID, day, position, match.UserID, match.Score, match.MatchReason); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UsersWithoutDailyBatch returns up to limit onboarded users who have no batch for the day yet,
+// in ID order after the given user ID. Pass the last ID returned to get the next chunk, so
+// users whose batch failed to build are not listed again in the same round.
+func (m MatchModel) UsersWithoutDailyBatch(day time.Time, afterUserID string, limit int) ([]string, error) {
+	query := `
+		SELECT p.user_id
+		FROM profiles p
+		WHERE ($2::uuid IS NULL OR p.user_id > $2::uuid)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM daily_match_batches b
+				WHERE b.user_id = p.user_id AND b.batch_date = $1
+			)
+		ORDER BY p.user_id
+		LIMIT $3`
+
+	var after any
+	if afterUserID != "" {
+		after = afterUserID
+	}
+	rows, err := m.DB.Query(query, BatchDay(day), after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// LockDailyBatches makes the caller the only one building batches until unlock is called,
+// so replicas running the daily job don't all run the matching query for the same users.
+// ok is false if someone else holds the lock.
+func (m MatchModel) LockDailyBatches(ctx context.Context) (unlock func(), ok bool, err error) {
+	// The lock is released when the transaction ends, even if the connection is lost
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('daily_match_batches'))`).Scan(&ok); err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	if !ok {
+		tx.Rollback()
+		return nil, false, nil
+	}
+	return func() { tx.Rollback() }, true, nil
+}
+
+// PurgeDailyBatches deletes batches that are too old to be paged through any more.
+func (m MatchModel) PurgeDailyBatches(today time.Time) (int64, error) {
+	cutoff := BatchDay(today).AddDate(0, 0, -dailyBatchRetentionDays)
+	result, err := m.DB.Exec(`DELETE FROM daily_match_batches WHERE batch_date < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetDailyMatches returns a page of the user's batch for the day, in its original order.
+// Suggestions the user has since liked, passed or started a conversation with are left out.
+// A cursor keeps paging through the batch it was issued for, even after midnight.
+func (m MatchModel) GetDailyMatches(userID string, day time.Time, cursor string, limit int) (*MatchPage, error) {
+	limit = matchPageSize(limit)
+
+	after := dailyCursor{Day: BatchDay(day), Position: -1}
+	if cursor != "" {
+		var err error
+		if after, err = decodeDailyCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		SELECT
+			dm.position,
+			dm.match_user_id,
+			p.gender,
+			p.opening_question,
+			dm.match_reason,
+			dm.score,
+			ai.storage_key AS audio_intro_key,
+			ai.duration_ms AS audio_intro_duration_ms
+		FROM
+			daily_matches dm
+		JOIN
+			daily_match_batches b ON b.user_id = dm.user_id AND b.batch_date = dm.batch_date
+		JOIN
+			profiles p ON p.user_id = dm.match_user_id
+		LEFT JOIN
+			audio_intros ai ON ai.user_id = dm.match_user_id
+		WHERE
+			dm.user_id = $1
+			AND dm.batch_date = $2
+			AND dm.position > $3
+			-- Decided on since the batch was picked
+			AND NOT EXISTS (
+				SELECT 1
+				FROM match_decisions md
+				WHERE md.user_id = dm.user_id
+					AND md.target_id = dm.match_user_id
+					AND md.decided_at >= b.created_at
+			)
+			-- Already talking, or blocked
+			AND NOT EXISTS (
+				SELECT 1
+				FROM conversations c
+				WHERE (c.user_a_id = dm.user_id AND c.user_b_id = dm.match_user_id)
+					OR (c.user_a_id = dm.match_user_id AND c.user_b_id = dm.user_id)
+			)
+		ORDER BY
+			dm.position
+		LIMIT $4`
+
+	// Fetch one extra row to find out whether another page exists
+	rows, err := m.DB.Query(query, userID, after.Day, after.Position, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &MatchPage{Matches: []MatchProfile{}}
+	var positions []int
+	for rows.Next() {
+		var match MatchProfile
+		var position int
+		var audioIntroKey sql.NullString
+		var audioIntroDuration sql.NullInt64
+
+		err := rows.Scan(&position, &match.UserID, &match.Gender, &match.OpeningQuestion, &match.MatchReason, &match.Score, &audioIntroKey, &audioIntroDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		match.DisplayName = Pseudonym(match.UserID)
+		if audioIntroKey.Valid {
+			match.HasAudioIntro = true
+			match.AudioIntroKey = audioIntroKey.String
+			match.AudioIntroDurationMs = int(audioIntroDuration.Int64)
+		}
+
+		page.Matches = append(page.Matches, match)
+		positions = append(positions, position)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Matches) > limit {
+		page.Matches = page.Matches[:limit]
+		page.NextCursor = encodeDailyCursor(dailyCursor{Day: after.Day, Position: positions[limit-1]})
+	}
+	return page, nil
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Decision is what a user did with a suggested match.
+type Decision string
+
+const (
+	DecisionLike Decision = "like"
+	DecisionPass Decision = "pass"
+)
+
+var (
+	ErrSelfDecision = errors.New("cannot like or pass on yourself")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// RecordDecision stores a like or pass on a suggested user, replacing any earlier decision.
+// mutual is true when this like completes a pair of likes, so it is reported only once.
+func (m MatchModel) RecordDecision(userID, targetID string, decision Decision) (mutual bool, err error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 1. The target must exist. Its ID is read back in canonical form for the lock below.
+	err = tx.QueryRow(`SELECT id::text FROM users WHERE id = $1::uuid`, targetID).Scan(&targetID)
+	if err == sql.ErrNoRows || isInvalidText(err) {
+		return false, ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	if targetID == userID {
+		return false, ErrSelfDecision
+	}
+
+	// 2. Decisions on the same pair take turns. Otherwise two likes sent at once
+	// each miss the other's uncommitted row, and neither reports the match.
+	lockQuery := `SELECT pg_advisory_xact_lock(hashtext(LEAST($1::text, $2::text) || ':' || GREATEST($1::text, $2::text)))`
+	if _, err := tx.Exec(lockQuery, userID, targetID); err != nil {
+		return false, err
+	}
+
+	// 3. Remember the previous decision, so a repeated like doesn't announce the match again
+	var previous Decision
+	err = tx.QueryRow(`SELECT decision FROM match_decisions WHERE user_id = $1 AND target_id = $2 FOR UPDATE`, userID, targetID).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	// 4. Store the decision
+	upsertQuery := `
+		INSERT INTO match_decisions (user_id, target_id, decision)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, target_id) DO UPDATE SET
+			decision = EXCLUDED.decision,
+			decided_at = NOW()`
+	if _, err := tx.Exec(upsertQuery, userID, targetID, decision); err != nil {
+		return false, err
+	}
+
+	// 5. A new like is mutual if the target already liked the user
+	if decision == DecisionLike && previous != DecisionLike {
+		likedBackQuery := `SELECT EXISTS(SELECT 1 FROM match_decisions WHERE user_id = $1 AND target_id = $2 AND decision = 'like')`
+		if err := tx.QueryRow(likedBackQuery, targetID, userID).Scan(&mutual); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return mutual, nil
+}
+
+// isInvalidText reports whether Postgres rejected a value as malformed, such as an ID that is not a UUID.
+func isInvalidText(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "22P02"
+}
@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeStep answers one query of a fakeDB script.
+type fakeStep struct {
+	// match is a piece of the query this step answers
+	match        string
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+}
+
+// fakeDB is a database/sql driver that answers queries from a script, in order.
+// It checks the decisions a model makes around its queries without a Postgres server.
+type fakeDB struct {
+	t     *testing.T
+	mu    sync.Mutex
+	steps []fakeStep
+	// log lists the queries run, and BEGIN, COMMIT and ROLLBACK
+	log []string
+}
+
+// newFakeDB opens a database that expects exactly the scripted queries.
+func newFakeDB(t *testing.T, steps ...fakeStep) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{t: t, steps: steps}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() {
+		db.Close()
+		if len(f.steps) > 0 {
+			t.Errorf("queries never run: %q", f.steps[0].match)
+		}
+	})
+	return db, f
+}
+
+// row is a shorthand for a step returning a single row.
+func row(match string, values ...driver.Value) fakeStep {
+	columns := make([]string, len(values))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	return fakeStep{match: match, columns: columns, rows: [][]driver.Value{values}}
+}
+
+// affected is a shorthand for a step of a statement changing n rows.
+func affected(match string, n int64) fakeStep {
+	return fakeStep{match: match, rowsAffected: n}
+}
+
+// ran reports whether a query starting with s was run.
+func (f *fakeDB) ran(s string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, q := range f.log {
+		if strings.HasPrefix(strings.TrimSpace(q), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeDB) record(s string) {
+	f.mu.Lock()
+	f.log = append(f.log, s)
+	f.mu.Unlock()
+}
+
+func (f *fakeDB) next(query string) fakeStep {
+	f.record(query)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.steps) == 0 {
+		f.t.Errorf("unexpected query: %s", query)
+		return fakeStep{err: fmt.Errorf("unexpected query")}
+	}
+	step := f.steps[0]
+	f.steps = f.steps[1:]
+	if !strings.Contains(query, step.match) {
+		f.t.Errorf("query %q does not contain %q", query, step.match)
+	}
+	return step
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ f *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn(d), nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.f, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	c.f.record("BEGIN")
+	return fakeTx(c), nil
+}
+
+type fakeTx struct{ f *fakeDB }
+
+func (tx fakeTx) Commit() error   { tx.f.record("COMMIT"); return nil }
+func (tx fakeTx) Rollback() error { tx.f.record("ROLLBACK"); return nil }
+
+type fakeStmt struct {
+	f     *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	step := s.f.next(s.query)
+	return driver.RowsAffected(step.rowsAffected), step.err
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	step := s.f.next(s.query)
+	if step.err != nil {
+		return nil, step.err
+	}
+	return &fakeRows{columns: step.columns, rows: step.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
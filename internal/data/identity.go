@@ -0,0 +1,63 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// pseudonymNouns are the words used to build anonymous display names.
+var pseudonymNouns = []string{
+	"Hiker", "Stargazer", "Wanderer", "Dreamer", "Explorer", "Voyager",
+	"Daydreamer", "Nomad", "Drifter", "Seeker", "Rambler", "Skipper",
+	"Tinkerer", "Storyteller", "Night Owl", "Early Bird",
+}
+
+// Pseudonym returns the stable anonymous name (e.g. "Hiker #4821") shown for a user
+// until names are unlocked. The same user ID always maps to the same pseudonym.
+func Pseudonym(userID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	sum := h.Sum32()
+
+	noun := pseudonymNouns[sum%uint32(len(pseudonymNouns))]
+	number := (sum/uint32(len(pseudonymNouns)))%9000 + 1000
+	return fmt.Sprintf("%s #%d", noun, number)
+}
+
+// VisibleName returns the name a viewer is allowed to see for a user:
+// the real display name once names are unlocked, the pseudonym otherwise.
+func VisibleName(userID, displayName string, namesUnlocked bool) string {
+	if namesUnlocked && displayName != "" {
+		return displayName
+	}
+	return Pseudonym(userID)
+}
+
+// RevealBetween reports whether two users have a conversation in which names and photos are unlocked.
+// A user can always see their own name and photos. A block hides both again, whatever was unlocked.
+func (m ConversationModel) RevealBetween(viewerID, otherID string) (namesUnlocked, photosUnlocked bool, err error) {
+	if viewerID == otherID {
+		return true, true, nil
+	}
+
+	// Conversations always store the lower ID as user A
+	userA, userB := viewerID, otherID
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+
+	var status ConversationStatus
+	query := `SELECT status, names_unlocked, photos_unlocked FROM conversations WHERE user_a_id = $1 AND user_b_id = $2`
+	err = m.DB.QueryRow(query, userA, userB).Scan(&status, &namesUnlocked, &photosUnlocked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	if status == StatusBlocked {
+		return false, false, nil
+	}
+	return namesUnlocked, photosUnlocked, nil
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestPseudonym(t *testing.T) {
+	format := regexp.MustCompile(`^[A-Za-z ]+ #[1-9][0-9]{3}$`)
+
+	ids := []string{
+		"",
+		"3f1c2b9e-8d4a-4c6e-9b1f-2a7d5e6c8b90",
+		"7a0e4d21-5b3c-4f8a-a1d2-9c8b7e6f5a43",
+		"00000000-0000-0000-0000-000000000000",
+	}
+	for _, id := range ids {
+		name := Pseudonym(id)
+		if !format.MatchString(name) {
+			t.Errorf("Pseudonym(%q) = %q, want a noun and a four digit number", id, name)
+		}
+		if again := Pseudonym(id); again != name {
+			t.Errorf("Pseudonym(%q) is not stable: %q then %q", id, name, again)
+		}
+	}
+
+	if Pseudonym(ids[1]) == Pseudonym(ids[2]) {
+		t.Errorf("different users got the same pseudonym %q", Pseudonym(ids[1]))
+	}
+}
+
+func TestVisibleName(t *testing.T) {
+	const userID = "3f1c2b9e-8d4a-4c6e-9b1f-2a7d5e6c8b90"
+	pseudonym := Pseudonym(userID)
+
+	tests := []struct {
+		name          string
+		displayName   string
+		namesUnlocked bool
+		want          string
+	}{
+		{name: "locked", displayName: "Alice", namesUnlocked: false, want: pseudonym},
+		{name: "unlocked", displayName: "Alice", namesUnlocked: true, want: "Alice"},
+		{name: "unlocked without a display name", displayName: "", namesUnlocked: true, want: pseudonym},
+		{name: "locked without a display name", displayName: "", namesUnlocked: false, want: pseudonym},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VisibleName(userID, tt.displayName, tt.namesUnlocked); got != tt.want {
+				t.Errorf("VisibleName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevealBetween(t *testing.T) {
+	const viewerID = "3f1c2b9e-8d4a-4c6e-9b1f-2a7d5e6c8b90"
+	const otherID = "7a0e4d21-5b3c-4f8a-a1d2-9c8b7e6f5a43"
+
+	tests := []struct {
+		name       string
+		status     ConversationStatus
+		wantNames  bool
+		wantPhotos bool
+	}{
+		{name: "active", status: StatusActive, wantNames: true, wantPhotos: true},
+		{name: "blocked after everything was unlocked", status: StatusBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, row("FROM conversations", string(tt.status), true, true))
+			names, photos, err := ConversationModel{DB: db}.RevealBetween(viewerID, otherID)
+			if err != nil {
+				t.Fatalf("RevealBetween() error = %v", err)
+			}
+			if names != tt.wantNames || photos != tt.wantPhotos {
+				t.Errorf("RevealBetween() = %v, %v, want %v, %v", names, photos, tt.wantNames, tt.wantPhotos)
+			}
+
+			wantName := "Alex"
+			if !tt.wantNames {
+				wantName = Pseudonym(otherID)
+			}
+			if got := VisibleName(otherID, "Alex", names); got != wantName {
+				t.Errorf("VisibleName() = %q, want %q", got, wantName)
+			}
+		})
+	}
+}
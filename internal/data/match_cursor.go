@@ -0,0 +1,61 @@
+package data
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMatchPageSize = 20
+	MaxMatchPageSize     = 50
+)
+
+// MatchPage is one page of the match feed, best scored first.
+type MatchPage struct {
+	Matches []MatchProfile `json:"matches"`
+	// NextCursor fetches the next page. Empty when there are no more matches.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// matchPageSize applies the default and the cap to a requested page size.
+func matchPageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultMatchPageSize
+	}
+	return min(limit, MaxMatchPageSize)
+}
+
+// dailyCursor is the position in a day's batch of suggestions.
+type dailyCursor struct {
+	Day      time.Time
+	Position int
+}
+
+// encodeDailyCursor builds the opaque cursor handed to clients paging through a batch.
+func encodeDailyCursor(c dailyCursor) string {
+	raw := c.Day.Format(time.DateOnly) + "|" + strconv.Itoa(c.Position)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeDailyCursor parses a cursor from encodeDailyCursor.
+func decodeDailyCursor(cursor string) (dailyCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return dailyCursor{}, ErrInvalidCursor
+	}
+	dayStr, positionStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return dailyCursor{}, ErrInvalidCursor
+	}
+	day, err := time.Parse(time.DateOnly, dayStr)
+	if err != nil {
+		return dailyCursor{}, ErrInvalidCursor
+	}
+	position, err := strconv.Atoi(positionStr)
+	if err != nil || position < 0 {
+		return dailyCursor{}, ErrInvalidCursor
+	}
+	return dailyCursor{Day: day, Position: position}, nil
+}
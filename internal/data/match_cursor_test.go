@@ -0,0 +1,89 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDailyCursorRoundTrip(t *testing.T) {
+	tests := []dailyCursor{
+		{Day: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), Position: 0},
+		{Day: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), Position: 49},
+		{Day: BatchDay(time.Date(2025, 12, 31, 23, 59, 0, 0, time.FixedZone("PST", -8*3600))), Position: 7},
+	}
+
+	for _, want := range tests {
+		got, err := decodeDailyCursor(encodeDailyCursor(want))
+		if err != nil {
+			t.Fatalf("decodeDailyCursor: %v", err)
+		}
+		if !got.Day.Equal(want.Day) || got.Position != want.Position {
+			t.Errorf("round trip of %+v = %+v", want, got)
+		}
+	}
+}
+
+func TestBatchDay(t *testing.T) {
+	// Days follow UTC, so 02:00 in India still belongs to the previous day
+	ist := time.FixedZone("IST", 5*3600+1800)
+	tests := []struct {
+		at   time.Time
+		want time.Time
+	}{
+		{at: time.Date(2025, 3, 14, 23, 30, 0, 0, ist), want: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{at: time.Date(2025, 3, 15, 2, 0, 0, 0, ist), want: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{at: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), want: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{at: time.Date(2025, 3, 14, 23, 59, 59, 999999999, time.UTC), want: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := BatchDay(tt.at); !got.Equal(tt.want) {
+			t.Errorf("BatchDay(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeDailyCursorRejectsGarbage(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "%%%"},
+		{name: "no separator", cursor: encode("2025-03-14")},
+		{name: "bad day", cursor: encode("2025-02-30|3")},
+		{name: "timestamp instead of a day", cursor: encode("2025-03-14T00:00:00Z|3")},
+		{name: "missing position", cursor: encode("2025-03-14|")},
+		{name: "position not a number", cursor: encode("2025-03-14|three")},
+		{name: "negative position", cursor: encode("2025-03-14|-1")},
+		{name: "message cursor", cursor: encode("2025-03-14T15:09:26Z|9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeDailyCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeDailyCursor(%q) error = %v, want ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestMatchPageSize(t *testing.T) {
+	tests := []struct{ limit, want int }{
+		{limit: 0, want: DefaultMatchPageSize},
+		{limit: -5, want: DefaultMatchPageSize},
+		{limit: 1, want: 1},
+		{limit: MaxMatchPageSize, want: MaxMatchPageSize},
+		{limit: MaxMatchPageSize + 1, want: MaxMatchPageSize},
+		{limit: 1 << 30, want: MaxMatchPageSize},
+	}
+
+	for _, tt := range tests {
+		if got := matchPageSize(tt.limit); got != tt.want {
+			t.Errorf("matchPageSize(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -3,43 +3,76 @@ package data
 import (
 	"database/sql"
 	"log"
+	"time"
 )
 
 // MatchProfile represents the anonymous data we show for a potential match.
 type MatchProfile struct {
-	UserID          string `json:"user_id"` // This is the other user's ID
-	DisplayName     string `json:"display_name"`
+	UserID          string `json:"user_id"`      // This is the other user's ID
+	DisplayName     string `json:"display_name"` // Always a pseudonym, names are revealed in conversations
+	Gender          string `json:"gender"`
 	MatchReason     string `json:"match_reason"`
 	OpeningQuestion string `json:"opening_question"`
-	// We'll add hasAudioIntro later when we do media uploads.
+	HasAudioIntro   bool   `json:"has_audio_intro"`
+	// Score ranks the matches, higher is better. See MatchWeights.
+	Score float64 `json:"score"`
+	// Only set when HasAudioIntro. The handler turns AudioIntroKey into a signed AudioIntroURL.
+	AudioIntroURL        string `json:"audio_intro_url,omitempty"`
+	AudioIntroDurationMs int    `json:"audio_intro_duration_ms,omitempty"`
+	AudioIntroKey        string `json:"-"`
 }
 
 type MatchModel struct {
 	DB *sql.DB
+	// PassCooldown is how long a passed user stays out of the feed. Zero keeps them out for good.
+	PassCooldown time.Duration
+	// Weights of the match score, DefaultMatchWeights if left empty
+	Weights MatchWeights
+	// DailyBatchSize is how many people are suggested per day, DefaultDailyBatchSize if left empty
+	DailyBatchSize int
 }
 
-// GetPotentialMatches finds suitable matches for a given user ID.
-func (m MatchModel) GetPotentialMatches(currentUserID string) ([]MatchProfile, error) {
+// GetPotentialMatches finds up to limit suitable matches for a given user ID, best scored first.
+// This is the heavy query behind the daily batches, the feed itself is served by GetDailyMatches.
+func (m MatchModel) GetPotentialMatches(currentUserID string, limit int) ([]MatchProfile, error) {
 	// This query is the heart of our matching engine.
 	// It's complex, so let's break it down:
 	// 1. We select from `users` aliased as `u2` (the potential match).
 	// 2. We JOIN their profile `p2`.
-	// 3. We use subqueries `(SELECT ...)` to get the current user's (`u1`) profile `p1`.
-	// 4. The WHERE clause enforces all our matching rules.
+	// 3. We join the current user (`u1`) and their profile `p1`.
+	// 4. LATERAL subqueries measure the interests both have and those of the current user,
+	//    weighted by the inverse document frequency from `interest_weights`.
+	// 5. The WHERE clause enforces all our matching rules.
+	// 6. The score combines the signals with the MatchWeights and orders the results.
 	query := `
+		WITH interest_weights AS (
+			-- log(users / users with the interest), plus one so an interest everybody has still counts
+			SELECT
+				interest_id,
+				LN((SELECT COUNT(DISTINCT user_id) FROM user_interests)::float8 / COUNT(*)) + 1 AS idf
+			FROM user_interests
+			GROUP BY interest_id
+		)
 		SELECT
 			u2.id,
 			p2.gender,
 			p2.opening_question,
-			-- This subquery finds ONE shared interest to use as the "match reason"
+			-- The rarest shared interest makes the best "match reason"
+			shared.rarest_interest,
+			ai.storage_key AS audio_intro_key,
+			ai.duration_ms AS audio_intro_duration_ms,
 			(
-				SELECT i.name
-				FROM user_interests ui1
-				JOIN user_interests ui2 ON ui1.interest_id = ui2.interest_id
-				JOIN interests i ON ui1.interest_id = i.id
-				WHERE ui1.user_id = u1.id AND ui2.user_id = u2.id
-				LIMIT 1
-			) AS shared_interest
+				$3::float8 * shared.count::float8 / GREATEST(mine.count, 1)
+				+ $4::float8 * shared.idf / GREATEST(mine.idf, 1)
+				+ $5::float8 / (1 + EXTRACT(EPOCH FROM NOW() - activity.last_active)::float8 / $7::float8)
+				+ $6::float8 * (
+					(CASE WHEN COALESCE(p2.pronouns, '') <> '' THEN 1 ELSE 0 END)
+					+ (CASE WHEN COALESCE(p2.opening_question, '') <> '' THEN 1 ELSE 0 END)
+					+ (CASE WHEN (SELECT COUNT(*) FROM user_interests ui WHERE ui.user_id = u2.id) >= 3 THEN 1 ELSE 0 END)
+					+ (CASE WHEN EXISTS (SELECT 1 FROM profile_photos pp WHERE pp.user_id = u2.id) THEN 1 ELSE 0 END)
+					+ (CASE WHEN ai.user_id IS NOT NULL THEN 1 ELSE 0 END)
+				)::float8 / 5
+			) AS score
 		FROM
 			users u1
 		JOIN
@@ -48,6 +81,34 @@ func (m MatchModel) GetPotentialMatches(currentUserID string) ([]MatchProfile, e
 			users u2 ON u1.id != u2.id -- Rule 1: Not the same user
 		JOIN
 			profiles p2 ON u2.id = p2.user_id -- Ensure potential match has a profile
+		LEFT JOIN
+			audio_intros ai ON ai.user_id = u2.id
+		CROSS JOIN LATERAL (
+			-- The current user's interests, as the yardstick for the shared ones
+			SELECT COUNT(*) AS count, COALESCE(SUM(iw.idf), 0) AS idf
+			FROM user_interests ui
+			JOIN interest_weights iw ON iw.interest_id = ui.interest_id
+			WHERE ui.user_id = u1.id
+		) mine
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS count,
+				COALESCE(SUM(iw.idf), 0) AS idf,
+				(ARRAY_AGG(i.name ORDER BY iw.idf DESC))[1] AS rarest_interest
+			FROM user_interests ui1
+			JOIN user_interests ui2 ON ui1.interest_id = ui2.interest_id AND ui2.user_id = u2.id
+			JOIN interest_weights iw ON iw.interest_id = ui1.interest_id
+			JOIN interests i ON i.id = ui1.interest_id
+			WHERE ui1.user_id = u1.id
+		) shared
+		CROSS JOIN LATERAL (
+			-- Online users are active right now, others as of their last session
+			SELECT GREATEST(
+				u2.created_at,
+				u2.last_seen_at,
+				(SELECT MAX(ps.heartbeat_at) FROM presence_sessions ps WHERE ps.user_id = u2.id)
+			) AS last_active
+		) activity
 		WHERE
 			u1.id = $1
 			-- Rule 2: The other user's gender is one the current user is interested in.
@@ -56,16 +117,35 @@ func (m MatchModel) GetPotentialMatches(currentUserID string) ([]MatchProfile, e
 			-- Rule 3: The current user's gender is one the other user is interested in.
 			AND p2.sexual_orientation ? p1.gender
 			-- Rule 4: They share at least one interest.
-			-- EXISTS is more efficient than a JOIN for just checking existence.
-			AND EXISTS (
+			AND shared.count > 0
+			-- Rule 5: They have no conversation yet, in either direction and whatever its status.
+			-- This also keeps out anyone who blocked, or was blocked by, the current user.
+			AND NOT EXISTS (
 				SELECT 1
-				FROM user_interests ui1
-				JOIN user_interests ui2 ON ui1.interest_id = ui2.interest_id
-				WHERE ui1.user_id = u1.id AND ui2.user_id = u2.id
-			);
+				FROM conversations c
+				WHERE (c.user_a_id = u1.id AND c.user_b_id = u2.id)
+					OR (c.user_a_id = u2.id AND c.user_b_id = u1.id)
+			)
+			-- Rule 6: The current user hasn't liked them yet, nor passed on them within the cooldown.
+			AND NOT EXISTS (
+				SELECT 1
+				FROM match_decisions md
+				WHERE md.user_id = u1.id
+					AND md.target_id = u2.id
+					AND (
+						md.decision = 'like'
+						OR $2 <= 0
+						OR md.decided_at > NOW() - $2 * INTERVAL '1 second'
+					)
+			)
+		ORDER BY
+			score DESC, u2.id
+		LIMIT $8;
 	`
 
-	rows, err := m.DB.Query(query, currentUserID)
+	w := m.weights()
+	rows, err := m.DB.Query(query, currentUserID, int64(m.PassCooldown.Seconds()),
+		w.SharedInterests, w.InterestRarity, w.Recency, w.Completeness, RecencyScale.Seconds(), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -75,12 +155,21 @@ func (m MatchModel) GetPotentialMatches(currentUserID string) ([]MatchProfile, e
 	for rows.Next() {
 		var match MatchProfile
 		var sharedInterest sql.NullString // Use sql.NullString for safety
+		var audioIntroKey sql.NullString
+		var audioIntroDuration sql.NullInt64
 
-		if err := rows.Scan(&match.UserID, &match.DisplayName, &match.OpeningQuestion, &sharedInterest); err != nil {
+		if err := rows.Scan(&match.UserID, &match.Gender, &match.OpeningQuestion, &sharedInterest, &audioIntroKey, &audioIntroDuration, &match.Score); err != nil {
 			log.Printf("Error scanning match row: %v", err)
 			continue // Skip problematic rows
 		}
 
+		match.DisplayName = Pseudonym(match.UserID)
+		if audioIntroKey.Valid {
+			match.HasAudioIntro = true
+			match.AudioIntroKey = audioIntroKey.String
+			match.AudioIntroDurationMs = int(audioIntroDuration.Int64)
+		}
+
 		if sharedInterest.Valid {
 			match.MatchReason = "Shared interest in " + sharedInterest.String
 		} else {
@@ -0,0 +1,391 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 100
+	// Most messages replayed to a reconnecting socket, older gaps must be fetched page by page
+	MaxReplayMessages = 500
+	// How long after sending a message its sender may still edit it. Deleting has no time limit.
+	MessageEditWindow = 15 * time.Minute
+)
+
+var (
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrMessageNotFound  = errors.New("message not found in this conversation")
+	ErrNotMessageSender = errors.New("only the sender can change this message")
+	ErrEditWindowClosed = errors.New("message can no longer be edited")
+	ErrMessageDeleted   = errors.New("message has been deleted")
+)
+
+// MessagePage is one page of a conversation's history, newest message first.
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	// NextCursor fetches the next (older) page. Empty when there is nothing older.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// messageQueryer is satisfied by both *sql.DB and *sql.Tx.
+type messageQueryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// EncodeMessageCursor builds the opaque keyset cursor pointing at a message.
+func EncodeMessageCursor(msg Message) string {
+	raw := msg.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + msg.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeMessageCursor splits a cursor back into its (created_at, id) key.
+func decodeMessageCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	// The id goes straight into a uuid parameter, so it has to be one
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || !isUUID(id) {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return t, id, nil
+}
+
+// GetMessages returns a page of messages older than the `before` cursor, newest first.
+// An empty cursor starts from the most recent message.
+func (m ConversationModel) GetMessages(conversationID, userID, before string, limit int) (*MessagePage, error) {
+	// 1. Verify the user is a participant
+	var isParticipant bool
+	checkQuery := `SELECT EXISTS(SELECT 1 FROM conversations WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2))`
+	if err := m.DB.QueryRow(checkQuery, conversationID, userID).Scan(&isParticipant); err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+
+	// 2. Fetch the page
+	return listMessages(m.DB, conversationID, before, limit)
+}
+
+// listMessages runs the keyset query on (created_at, id) behind GetMessages.
+func listMessages(q messageQueryer, conversationID, before string, limit int) (*MessagePage, error) {
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	}
+	if limit > MaxMessagePageSize {
+		limit = MaxMessagePageSize
+	}
+
+	// Fetch one extra row to find out whether an older page exists
+	args := []any{conversationID, limit + 1}
+	keysetClause := ""
+	if before != "" {
+		createdAt, id, err := decodeMessageCursor(before)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		keysetClause = "AND (created_at, id) < ($3::timestamptz, $4::uuid)"
+	}
+
+	query := `
+		SELECT id, conversation_id, sender_id, content, is_opening_message, created_at, edited_at, deleted_at
+		FROM messages
+		WHERE conversation_id = $1 ` + keysetClause + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &MessagePage{Messages: []Message{}}
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.IsOpeningMessage, &msg.CreatedAt, &msg.EditedAt, &msg.DeletedAt); err != nil {
+			return nil, err
+		}
+		page.Messages = append(page.Messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Messages) > limit {
+		page.Messages = page.Messages[:limit]
+		page.NextCursor = EncodeMessageCursor(page.Messages[limit-1])
+	}
+
+	if err := loadMessageExtras(q, page.Messages); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetMessagesSince returns the messages created after `since`, oldest first, for replaying to a
+// reconnecting socket. `since` is either a message ID or a cursor from EncodeMessageCursor.
+// At most limit messages are returned; truncated reports whether more were left out.
+func (m ConversationModel) GetMessagesSince(conversationID, since string, limit int) (messages []Message, truncated bool, err error) {
+	// 1. Resolve `since` to a (created_at, id) key
+	createdAt, id, err := decodeMessageCursor(since)
+	if err != nil {
+		if !isUUID(since) {
+			return nil, false, ErrInvalidCursor
+		}
+		lookupQuery := `SELECT created_at, id FROM messages WHERE id = $1::uuid AND conversation_id = $2`
+		err = m.DB.QueryRow(lookupQuery, since, conversationID).Scan(&createdAt, &id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, false, ErrInvalidCursor
+			}
+			return nil, false, err
+		}
+	}
+
+	// 2. Fetch everything after it, one extra row to detect truncation
+	query := `
+		SELECT id, conversation_id, sender_id, content, is_opening_message, created_at, edited_at, deleted_at
+		FROM messages
+		WHERE conversation_id = $1 AND (created_at, id) > ($2::timestamptz, $3::uuid)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $4`
+
+	rows, err := m.DB.Query(query, conversationID, createdAt, id, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.IsOpeningMessage, &msg.CreatedAt, &msg.EditedAt, &msg.DeletedAt); err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(messages) > limit {
+		messages, truncated = messages[:limit], true
+	}
+
+	if err := loadMessageExtras(m.DB, messages); err != nil {
+		return nil, false, err
+	}
+	return messages, truncated, nil
+}
+
+// MarkRead records that a user has read a conversation up to and including a message.
+// The read position only ever moves forward; advanced is false if the user had already read further.
+// It also returns the other participant, who should get the read receipt.
+func (m ConversationModel) MarkRead(conversationID, userID, messageID string) (otherUserID string, advanced bool, err error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	// 1. Verify the user is a participant
+	var userA, userB string
+	convQuery := `SELECT user_a_id, user_b_id FROM conversations WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2)`
+	if err := tx.QueryRow(convQuery, conversationID, userID).Scan(&userA, &userB); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, ErrNotParticipant
+		}
+		return "", false, err
+	}
+	otherUserID = userA
+	if userID == userA {
+		otherUserID = userB
+	}
+
+	// 2. Find the message's position
+	if !isUUID(messageID) {
+		return "", false, ErrMessageNotFound
+	}
+	var readAt time.Time
+	msgQuery := `SELECT created_at FROM messages WHERE id = $1::uuid AND conversation_id = $2`
+	if err := tx.QueryRow(msgQuery, messageID, conversationID).Scan(&readAt); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, ErrMessageNotFound
+		}
+		return "", false, err
+	}
+
+	// 3. Move the read position forward
+	upsertQuery := `
+		INSERT INTO conversation_reads (conversation_id, user_id, last_read_message_id, last_read_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE SET
+			last_read_message_id = EXCLUDED.last_read_message_id,
+			last_read_at = EXCLUDED.last_read_at,
+			updated_at = NOW()
+		WHERE conversation_reads.last_read_at < EXCLUDED.last_read_at`
+
+	result, err := tx.Exec(upsertQuery, conversationID, userID, messageID, readAt)
+	if err != nil {
+		return "", false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", false, err
+	}
+	return otherUserID, rows > 0, nil
+}
+
+// EditMessage replaces the content of a message. Only its sender may edit it, within MessageEditWindow,
+// and only while the conversation is still open.
+func (m ConversationModel) EditMessage(conversationID, messageID, userID, content string) (*Message, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Load the message, locking it against a concurrent edit or delete
+	msg, status, err := lockMessage(tx, conversationID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Check the rules
+	if msg.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	if msg.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if status == StatusBlocked {
+		return nil, ErrConversationBlocked
+	}
+	if status == StatusDeclined || status == StatusExpired {
+		return nil, ErrConversationClosed
+	}
+	if time.Since(msg.CreatedAt) > MessageEditWindow {
+		return nil, ErrEditWindowClosed
+	}
+
+	// 3. Apply the edit
+	updateQuery := `UPDATE messages SET content = $1, edited_at = NOW() WHERE id = $2 RETURNING content, edited_at`
+	if err := tx.QueryRow(updateQuery, content, msg.ID).Scan(&msg.Content, &msg.EditedAt); err != nil {
+		return nil, err
+	}
+
+	// 4. Keep the reactions and attachment, so the edited message replaces the old one as a whole
+	edited := []Message{*msg}
+	if err := loadMessageExtras(tx, edited); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &edited[0], nil
+}
+
+// DeleteMessage unsends a message: its content is erased and a tombstone with deleted_at is kept
+// in its place, so the history and read positions stay intact. Only the sender may delete it,
+// and not once the conversation is blocked.
+// If the message had an attachment, its storage key is returned so the caller can remove the file.
+func (m ConversationModel) DeleteMessage(conversationID, messageID, userID string) (msg *Message, removedBlob string, err error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	// 1. Load the message, locking it against a concurrent edit or delete
+	msg, status, err := lockMessage(tx, conversationID, messageID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 2. Check the rules
+	if msg.SenderID != userID {
+		return nil, "", ErrNotMessageSender
+	}
+	if msg.DeletedAt != nil {
+		return nil, "", ErrMessageDeleted
+	}
+	if status == StatusBlocked {
+		return nil, "", ErrConversationBlocked
+	}
+
+	// 3. Erase the content, keeping the tombstone
+	updateQuery := `UPDATE messages SET content = '', deleted_at = NOW() WHERE id = $1 RETURNING content, deleted_at`
+	if err := tx.QueryRow(updateQuery, msg.ID).Scan(&msg.Content, &msg.DeletedAt); err != nil {
+		return nil, "", err
+	}
+	if _, err := tx.Exec(`DELETE FROM message_reactions WHERE message_id = $1`, msg.ID); err != nil {
+		return nil, "", err
+	}
+	err = tx.QueryRow(`DELETE FROM attachments WHERE message_id = $1 RETURNING storage_key`, msg.ID).Scan(&removedBlob)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return msg, removedBlob, nil
+}
+
+// loadMessageExtras fills in the reactions and attachments of the given messages.
+func loadMessageExtras(q messageQueryer, messages []Message) error {
+	if err := attachReactions(q, messages); err != nil {
+		return err
+	}
+	return attachAttachments(q, messages)
+}
+
+// lockMessage loads a message for EditMessage and DeleteMessage along with its conversation's status,
+// after checking the user is a participant.
+func lockMessage(tx *sql.Tx, conversationID, messageID, userID string) (*Message, ConversationStatus, error) {
+	var status ConversationStatus
+	convQuery := `SELECT status FROM conversations WHERE id = $1 AND (user_a_id = $2 OR user_b_id = $2)`
+	if err := tx.QueryRow(convQuery, conversationID, userID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", ErrNotParticipant
+		}
+		return nil, "", err
+	}
+
+	if !isUUID(messageID) {
+		return nil, "", ErrMessageNotFound
+	}
+	var msg Message
+	msgQuery := `
+		SELECT id, conversation_id, sender_id, content, is_opening_message, created_at, edited_at, deleted_at
+		FROM messages
+		WHERE id = $1::uuid AND conversation_id = $2
+		FOR UPDATE`
+	err := tx.QueryRow(msgQuery, messageID, conversationID).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.IsOpeningMessage, &msg.CreatedAt, &msg.EditedAt, &msg.DeletedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", ErrMessageNotFound
+		}
+		return nil, "", err
+	}
+	return &msg, status, nil
+}
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMessageCursorRoundTrip(t *testing.T) {
+	msg := Message{
+		ID:        "9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c",
+		CreatedAt: time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.FixedZone("IST", 5*3600+1800)),
+	}
+
+	createdAt, id, err := decodeMessageCursor(EncodeMessageCursor(msg))
+	if err != nil {
+		t.Fatalf("decodeMessageCursor: %v", err)
+	}
+	if !createdAt.Equal(msg.CreatedAt) {
+		t.Errorf("created_at = %v, want %v", createdAt, msg.CreatedAt)
+	}
+	if id != msg.ID {
+		t.Errorf("id = %q, want %q", id, msg.ID)
+	}
+}
+
+func TestDecodeMessageCursorRejectsGarbage(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "%%%"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("2025-03-14T15:09:26Z|a"))},
+		{name: "no separator", cursor: encode("2025-03-14T15:09:26Z")},
+		{name: "missing id", cursor: encode("2025-03-14T15:09:26Z|")},
+		{name: "bad timestamp", cursor: encode("yesterday|9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c")},
+		{name: "id is not a uuid", cursor: encode("2025-03-14T15:09:26Z|9b2e6c1a")},
+		{name: "id with trailing garbage", cursor: encode("2025-03-14T15:09:26Z|9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c'")},
+		{name: "message id instead of a cursor", cursor: "9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeMessageCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeMessageCursor(%q) error = %v, want ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestDeleteMessageRefusesBlockedConversation(t *testing.T) {
+	const messageID = "9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c"
+	db, fake := newFakeDB(t,
+		row("SELECT status FROM conversations", string(StatusBlocked)),
+		row("FROM messages", messageID, "conv-1", "user-a", "hi", false, time.Now(), nil, nil),
+	)
+	model := ConversationModel{DB: db}
+
+	_, removedBlob, err := model.DeleteMessage("conv-1", messageID, "user-a")
+	if !errors.Is(err, ErrConversationBlocked) {
+		t.Fatalf("DeleteMessage() error = %v, want ErrConversationBlocked", err)
+	}
+	if removedBlob != "" || fake.ran("UPDATE") || fake.ran("DELETE") || fake.ran("COMMIT") {
+		t.Errorf("DeleteMessage() changed a blocked conversation: %q", fake.log)
+	}
+}
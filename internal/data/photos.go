@@ -0,0 +1,190 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MaxProfilePhotos is how many photos a profile can hold.
+const MaxProfilePhotos = 6
+
+var (
+	ErrTooManyPhotos     = errors.New("profile already has the maximum number of photos")
+	ErrPhotoNotFound     = errors.New("photo not found")
+	ErrInvalidPhotoOrder = errors.New("photo order must list each of the user's photos exactly once")
+)
+
+// Photo is one profile photo. The full image and its thumbnail live in a blob store.
+type Photo struct {
+	ID           string    `json:"id"`
+	Position     int       `json:"position"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Placeholder  string    `json:"placeholder"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AddPhoto appends a photo to the end of the user's profile, filling in its ID and position.
+func (m ProfileModel) AddPhoto(userID string, photo *Photo) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. Lock the user so concurrent uploads can't both take the last slot
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	// 2. Check there is room
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM profile_photos WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxProfilePhotos {
+		return ErrTooManyPhotos
+	}
+
+	// 3. Insert it last
+	insertQuery := `
+		INSERT INTO profile_photos (user_id, position, storage_key, thumbnail_key, placeholder)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, position, created_at`
+	err = tx.QueryRow(insertQuery, userID, count, photo.StorageKey, photo.ThumbnailKey, photo.Placeholder).Scan(&photo.ID, &photo.Position, &photo.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeletePhoto removes one of the user's photos and closes the gap it leaves in the order.
+// The deleted photo is returned so the caller can remove its files.
+func (m ProfileModel) DeletePhoto(userID, photoID string) (*Photo, error) {
+	if !isUUID(photoID) {
+		return nil, ErrPhotoNotFound
+	}
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Delete it
+	var photo Photo
+	deleteQuery := `
+		DELETE FROM profile_photos
+		WHERE id = $1::uuid AND user_id = $2
+		RETURNING id, position, storage_key, thumbnail_key, placeholder, created_at`
+	err = tx.QueryRow(deleteQuery, photoID, userID).Scan(&photo.ID, &photo.Position, &photo.StorageKey, &photo.ThumbnailKey, &photo.Placeholder, &photo.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, err
+	}
+
+	// 2. Shift the photos after it up by one
+	shiftQuery := `UPDATE profile_photos SET position = position - 1 WHERE user_id = $1 AND position > $2`
+	if _, err := tx.Exec(shiftQuery, userID, photo.Position); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &photo, nil
+}
+
+// ReorderPhotos puts the user's photos in the given order. photoIDs must name every photo once.
+func (m ProfileModel) ReorderPhotos(userID string, photoIDs []string) ([]Photo, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. The new order must be a permutation of the current photos
+	current, err := listPhotos(tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(photoIDs) != len(current) {
+		return nil, ErrInvalidPhotoOrder
+	}
+	known := make(map[string]bool, len(current))
+	for _, p := range current {
+		known[p.ID] = true
+	}
+	for _, id := range photoIDs {
+		if !known[id] {
+			return nil, ErrInvalidPhotoOrder
+		}
+		delete(known, id) // Catches duplicates
+	}
+
+	// 2. Apply it. The unique position constraint is only checked at commit.
+	for position, id := range photoIDs {
+		if _, err := tx.Exec(`UPDATE profile_photos SET position = $1 WHERE id = $2`, position, id); err != nil {
+			return nil, err
+		}
+	}
+
+	photos, err := listPhotos(tx, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
+
+// GetPhotos returns the user's photos in display order.
+func (m ProfileModel) GetPhotos(userID string) ([]Photo, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	photos, err := listPhotos(tx, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	return photos, tx.Commit()
+}
+
+// listPhotos loads a user's photos in order, optionally locking them for an update.
+func listPhotos(tx *sql.Tx, userID string, forUpdate bool) ([]Photo, error) {
+	query := `
+		SELECT id, position, storage_key, thumbnail_key, placeholder, created_at
+		FROM profile_photos
+		WHERE user_id = $1
+		ORDER BY position ASC`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := []Photo{}
+	for rows.Next() {
+		var p Photo
+		if err := rows.Scan(&p.ID, &p.Position, &p.StorageKey, &p.ThumbnailKey, &p.Placeholder, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		photos = append(photos, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
@@ -0,0 +1,231 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PresenceStaleAfter is how long a presence session lives without a heartbeat.
+// API instances must heartbeat more often than this.
+const PresenceStaleAfter = 90 * time.Second
+
+// PresenceChange is a user going online or offline across all API instances.
+type PresenceChange struct {
+	UserID     string     `json:"user_id"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	// Hidden users chose not to share their presence, so nobody should be told
+	Hidden bool `json:"-"`
+}
+
+// Partner is the other participant of one of a user's conversations.
+type Partner struct {
+	ConversationID string
+	UserID         string
+}
+
+type PresenceModel struct {
+	DB *sql.DB
+}
+
+// Connect records that an instance holds a socket for the user.
+// It returns a change if the user was offline on every instance until now, nil otherwise.
+func (m PresenceModel) Connect(instanceID, userID string) (*PresenceChange, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Was the user already online somewhere?
+	if err := lockPresence(tx, userID); err != nil {
+		return nil, err
+	}
+	var wasOnline, hidden bool
+	checkQuery := `
+		SELECT
+			EXISTS (
+				SELECT 1 FROM presence_sessions
+				WHERE user_id = $1 AND heartbeat_at > NOW() - $2 * INTERVAL '1 second'
+			),
+			(SELECT hide_last_seen FROM users WHERE id = $1)`
+	if err := tx.QueryRow(checkQuery, userID, int64(PresenceStaleAfter.Seconds())).Scan(&wasOnline, &hidden); err != nil {
+		return nil, err
+	}
+
+	// 2. Register this instance's session
+	sessionQuery := `
+		INSERT INTO presence_sessions (instance_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (instance_id, user_id) DO UPDATE SET heartbeat_at = NOW()`
+	if _, err := tx.Exec(sessionQuery, instanceID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if wasOnline {
+		return nil, nil
+	}
+	return &PresenceChange{UserID: userID, Online: true, Hidden: hidden}, nil
+}
+
+// Disconnect removes the instance's session for the user. If the user is now offline everywhere,
+// their last_seen_at is stamped and the change is returned, nil otherwise.
+func (m PresenceModel) Disconnect(instanceID, userID string) (*PresenceChange, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Drop this instance's session. The lock makes a concurrent connect or disconnect
+	// on another instance wait, so the count below sees its session.
+	if err := lockPresence(tx, userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM presence_sessions WHERE instance_id = $1 AND user_id = $2`, instanceID, userID); err != nil {
+		return nil, err
+	}
+
+	// 2. Still online on another instance?
+	var stillOnline bool
+	checkQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM presence_sessions
+			WHERE user_id = $1 AND heartbeat_at > NOW() - $2 * INTERVAL '1 second'
+		)`
+	if err := tx.QueryRow(checkQuery, userID, int64(PresenceStaleAfter.Seconds())).Scan(&stillOnline); err != nil {
+		return nil, err
+	}
+	if stillOnline {
+		return nil, tx.Commit()
+	}
+
+	// 3. Offline everywhere, remember when we last saw them
+	change := PresenceChange{UserID: userID}
+	var lastSeen time.Time
+	updateQuery := `UPDATE users SET last_seen_at = NOW() WHERE id = $1 RETURNING last_seen_at, hide_last_seen`
+	if err := tx.QueryRow(updateQuery, userID).Scan(&lastSeen, &change.Hidden); err != nil {
+		return nil, err
+	}
+	change.LastSeenAt = &lastSeen
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// lockPresence serializes the presence changes of one user across instances until tx ends.
+func lockPresence(tx *sql.Tx, userID string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1::text))`, "presence:"+userID)
+	return err
+}
+
+// Heartbeat refreshes the sessions of every user the instance holds a socket for,
+// and drops the instance's sessions for anyone else.
+func (m PresenceModel) Heartbeat(instanceID string, userIDs []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsertQuery := `
+		INSERT INTO presence_sessions (instance_id, user_id)
+		SELECT $1, u FROM unnest($2::uuid[]) AS u
+		ON CONFLICT (instance_id, user_id) DO UPDATE SET heartbeat_at = NOW()`
+	if _, err := tx.Exec(upsertQuery, instanceID, pq.Array(userIDs)); err != nil {
+		return err
+	}
+
+	deleteQuery := `DELETE FROM presence_sessions WHERE instance_id = $1 AND NOT (user_id = ANY($2::uuid[]))`
+	if _, err := tx.Exec(deleteQuery, instanceID, pq.Array(userIDs)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExpireStale removes sessions whose instance stopped heartbeating, e.g. because it crashed,
+// and returns the users that are now offline everywhere.
+func (m PresenceModel) ExpireStale() ([]PresenceChange, error) {
+	query := `
+		WITH expired AS (
+			DELETE FROM presence_sessions
+			WHERE heartbeat_at < NOW() - $1 * INTERVAL '1 second'
+			RETURNING user_id, heartbeat_at
+		), offline AS (
+			SELECT user_id, MAX(heartbeat_at) AS last_seen_at
+			FROM expired
+			WHERE NOT EXISTS (
+				SELECT 1 FROM presence_sessions ps
+				WHERE ps.user_id = expired.user_id
+					AND ps.heartbeat_at >= NOW() - $1 * INTERVAL '1 second'
+			)
+			GROUP BY user_id
+		)
+		UPDATE users u
+		SET last_seen_at = offline.last_seen_at
+		FROM offline
+		WHERE u.id = offline.user_id
+		RETURNING u.id, u.last_seen_at, u.hide_last_seen`
+
+	rows, err := m.DB.Query(query, int64(PresenceStaleAfter.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PresenceChange
+	for rows.Next() {
+		var change PresenceChange
+		var lastSeen time.Time
+		if err := rows.Scan(&change.UserID, &lastSeen, &change.Hidden); err != nil {
+			return nil, err
+		}
+		change.LastSeenAt = &lastSeen
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Partners lists the other participants of the user's pending and active conversations.
+// Blocked, declined and expired ones are skipped.
+func (m PresenceModel) Partners(userID string) ([]Partner, error) {
+	query := `
+		SELECT
+			id,
+			CASE WHEN user_a_id = $1 THEN user_b_id ELSE user_a_id END
+		FROM conversations
+		WHERE (user_a_id = $1 OR user_b_id = $1) AND status IN ('pending', 'active')`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partners []Partner
+	for rows.Next() {
+		var p Partner
+		if err := rows.Scan(&p.ConversationID, &p.UserID); err != nil {
+			return nil, err
+		}
+		partners = append(partners, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return partners, nil
+}
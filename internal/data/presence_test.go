@@ -0,0 +1,27 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDisconnectLocksBeforeCounting(t *testing.T) {
+	// The script runs in order, so the user's lock has to come before the session count
+	db, fake := newFakeDB(t,
+		affected("pg_advisory_xact_lock", 0),
+		affected("DELETE FROM presence_sessions", 1),
+		row("SELECT EXISTS", false),
+		row("UPDATE users", time.Now(), false),
+	)
+
+	change, err := PresenceModel{DB: db}.Disconnect("instance-1", "user-a")
+	if err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	if change == nil || change.Online || change.LastSeenAt == nil {
+		t.Errorf("Disconnect() = %+v, want the user going offline", change)
+	}
+	if !fake.ran("COMMIT") {
+		t.Error("Disconnect() did not commit")
+	}
+}
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Longest accepted reaction. Emoji with skin tones and ZWJ sequences take several runes.
+const maxReactionRunes = 10
+
+var (
+	ErrInvalidReaction  = errors.New("reaction must be a single emoji")
+	ErrReactionNotFound = errors.New("no reaction from this user on the message")
+)
+
+// Reaction is one user's emoji on a message.
+type Reaction struct {
+	UserID    string    `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionResult is what SetReaction changed.
+type ReactionResult struct {
+	Reaction Reaction
+	// Activated is true if the reaction accepted a pending request, see ConversationModel.ReactionsAcceptRequests
+	Activated bool
+}
+
+// validReaction checks that the value is a single emoji, see isSingleEmoji.
+func validReaction(emoji string) bool {
+	n := utf8.RuneCountInString(emoji)
+	if n == 0 || n > maxReactionRunes {
+		return false
+	}
+	return isSingleEmoji(emoji)
+}
+
+// SetReaction adds the user's reaction to a message, replacing any earlier one.
+// In a pending conversation only the recipient may react; whether that accepts the request
+// depends on ReactionsAcceptRequests.
+func (m ConversationModel) SetReaction(conversationID, messageID, userID, emoji string) (*ReactionResult, error) {
+	if !validReaction(emoji) {
+		return nil, ErrInvalidReaction
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Load the message and its conversation's status
+	msg, status, err := lockMessage(tx, conversationID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Check the rules
+	if msg.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if status == StatusBlocked {
+		return nil, ErrConversationBlocked
+	}
+	if status == StatusDeclined || status == StatusExpired {
+		return nil, ErrConversationClosed
+	}
+
+	result := &ReactionResult{}
+	if status == StatusPending {
+		var openerID string
+		openingQuery := `SELECT sender_id FROM messages WHERE conversation_id = $1 AND is_opening_message LIMIT 1`
+		if err := tx.QueryRow(openingQuery, conversationID).Scan(&openerID); err != nil {
+			return nil, err
+		}
+		// Same rule as AddMessage: the sender waits for the recipient
+		if userID == openerID {
+			return nil, ErrAwaitingReply
+		}
+
+		// 3. Optionally treat the recipient's reaction as their reply
+		if m.ReactionsAcceptRequests {
+			if _, err := tx.Exec(`UPDATE conversations SET status = 'active' WHERE id = $1`, conversationID); err != nil {
+				return nil, err
+			}
+			result.Activated = true
+		}
+	}
+
+	// 4. Store the reaction
+	upsertQuery := `
+		INSERT INTO message_reactions (message_id, user_id, emoji)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id, user_id) DO UPDATE SET emoji = EXCLUDED.emoji, created_at = NOW()
+		RETURNING user_id, emoji, created_at`
+	err = tx.QueryRow(upsertQuery, msg.ID, userID, emoji).Scan(&result.Reaction.UserID, &result.Reaction.Emoji, &result.Reaction.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RemoveReaction takes the user's reaction off a message.
+func (m ConversationModel) RemoveReaction(conversationID, messageID, userID string) error {
+	if !isUUID(messageID) {
+		return ErrReactionNotFound
+	}
+	query := `
+		DELETE FROM message_reactions mr
+		USING messages m, conversations c
+		WHERE mr.message_id = m.id
+			AND m.conversation_id = c.id
+			AND m.id = $1::uuid
+			AND c.id = $2
+			AND mr.user_id = $3
+			AND (c.user_a_id = $3 OR c.user_b_id = $3)`
+
+	result, err := m.DB.Exec(query, messageID, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrReactionNotFound
+	}
+	return nil
+}
+
+// attachReactions fills in the reactions of the given messages with a single query.
+func attachReactions(q messageQueryer, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(messages))
+	byID := make(map[string]*Message, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+		byID[messages[i].ID] = &messages[i]
+	}
+
+	query := `
+		SELECT message_id, user_id, emoji, created_at
+		FROM message_reactions
+		WHERE message_id = ANY($1::uuid[])
+		ORDER BY created_at ASC`
+
+	rows, err := q.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var r Reaction
+		if err := rows.Scan(&messageID, &r.UserID, &r.Emoji, &r.CreatedAt); err != nil {
+			return err
+		}
+		if msg, ok := byID[messageID]; ok {
+			msg.Reactions = append(msg.Reactions, r)
+		}
+	}
+	return rows.Err()
+}
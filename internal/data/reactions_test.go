@@ -0,0 +1,59 @@
+package data
+
+import "testing"
+
+func TestValidReaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		want  bool
+	}{
+		{name: "emoji", emoji: "😂", want: true},
+		{name: "thumbs up", emoji: "👍", want: true},
+		{name: "text style heart", emoji: "❤", want: true},
+		{name: "heart with VS16", emoji: "❤️", want: true},
+		{name: "skin tone", emoji: "👍🏽", want: true},
+		{name: "flag", emoji: "🇮🇳", want: true},
+		{name: "keycap", emoji: "1️⃣", want: true},
+		{name: "keycap without VS16", emoji: "#⃣", want: true},
+		{name: "ZWJ sequence", emoji: "👩‍💻", want: true},
+		{name: "ZWJ sequence with skin tone", emoji: "👩🏽‍💻", want: true},
+		{name: "ZWJ sequence with VS16", emoji: "🏳️‍🌈", want: true},
+		{name: "heart on fire", emoji: "❤️‍🔥", want: true},
+		{name: "family", emoji: "👨‍👩‍👧‍👦", want: true},
+		{name: "subdivision flag", emoji: "🏴\U000E0067\U000E0062\U000E0065\U000E006E\U000E0067\U000E007F", want: true},
+		{name: "copyright sign", emoji: "©", want: true},
+
+		{name: "empty", emoji: "", want: false},
+		{name: "digit", emoji: "1", want: false},
+		{name: "punctuation", emoji: "!!!", want: false},
+		{name: "currency", emoji: "$", want: false},
+		{name: "arrow", emoji: "→", want: false},
+		{name: "letter", emoji: "a", want: false},
+		{name: "word", emoji: "lol", want: false},
+		{name: "CJK", emoji: "好", want: false},
+		{name: "two emoji", emoji: "👍👍", want: false},
+		{name: "trailing space", emoji: "👍 ", want: false},
+		{name: "text around it", emoji: "ok👍", want: false},
+		{name: "half a flag", emoji: "🇮", want: false},
+		{name: "lone skin tone", emoji: "🏽", want: false},
+		{name: "two skin tones", emoji: "👍🏽🏽", want: false},
+		{name: "lone VS16", emoji: "\uFE0F", want: false},
+		{name: "text presentation selector", emoji: "❤\uFE0E", want: false},
+		{name: "leading ZWJ", emoji: "\u200D👍", want: false},
+		{name: "trailing ZWJ", emoji: "👍\u200D", want: false},
+		{name: "double ZWJ", emoji: "👩\u200D\u200D💻", want: false},
+		{name: "keycap base without keycap", emoji: "1\uFE0F", want: false},
+		{name: "unterminated tag sequence", emoji: "🏴\U000E0067\U000E0062", want: false},
+		{name: "control character", emoji: "👍\n", want: false},
+		{name: "too long", emoji: "❤‍❤‍❤‍❤‍❤‍❤", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validReaction(tt.emoji); got != tt.want {
+				t.Errorf("validReaction(%+q) = %v, want %v", tt.emoji, got, tt.want)
+			}
+		})
+	}
+}
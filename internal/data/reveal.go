@@ -0,0 +1,89 @@
+package data
+
+import (
+	"database/sql"
+)
+
+// Features that the reveal engine can unlock for a conversation.
+const (
+	RevealNames  = "names"
+	RevealPhotos = "photos"
+)
+
+// RevealState is the progressive reveal state of a conversation after a new message.
+type RevealState struct {
+	ConversationID string `json:"conversation_id"`
+	MessageCount   int    `json:"message_count"`
+	NamesUnlocked  bool   `json:"names_unlocked"`
+	PhotosUnlocked bool   `json:"photos_unlocked"`
+	// Unlocked lists the features this message newly unlocked, if any.
+	Unlocked []string `json:"unlocked"`
+}
+
+// advanceReveal is the reveal engine. It must be called inside the same transaction
+// that inserted a message: it bumps the conversation's message_count and flips
+// names_unlocked / photos_unlocked once BOTH participants have sent at least
+// the conversation's threshold number of messages.
+func advanceReveal(tx *sql.Tx, conversationID string) (*RevealState, error) {
+	// 1. Bump the counter and read back the current reveal settings
+	var userA, userB string
+	var namesThreshold, photosThreshold int
+	state := RevealState{ConversationID: conversationID}
+
+	bumpQuery := `
+		UPDATE conversations
+		SET message_count = message_count + 1
+		WHERE id = $1
+		RETURNING user_a_id, user_b_id, message_count, names_unlocked, photos_unlocked,
+			names_unlock_threshold, photos_unlock_threshold`
+
+	err := tx.QueryRow(bumpQuery, conversationID).Scan(
+		&userA, &userB, &state.MessageCount, &state.NamesUnlocked, &state.PhotosUnlocked,
+		&namesThreshold, &photosThreshold,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing left to unlock, so skip counting messages.
+	if state.NamesUnlocked && state.PhotosUnlocked {
+		return &state, nil
+	}
+
+	// 2. Count how many messages each side has sent and not deleted. The quieter side decides.
+	var sentByA, sentByB int
+	countQuery := `
+		SELECT
+			COUNT(*) FILTER (WHERE sender_id = $2),
+			COUNT(*) FILTER (WHERE sender_id = $3)
+		FROM messages
+		WHERE conversation_id = $1 AND deleted_at IS NULL`
+
+	if err := tx.QueryRow(countQuery, conversationID, userA, userB).Scan(&sentByA, &sentByB); err != nil {
+		return nil, err
+	}
+	state.unlock(min(sentByA, sentByB), namesThreshold, photosThreshold)
+
+	// 3. Persist any newly unlocked features
+	if len(state.Unlocked) > 0 {
+		unlockQuery := `UPDATE conversations SET names_unlocked = $2, photos_unlocked = $3 WHERE id = $1`
+		if _, err := tx.Exec(unlockQuery, conversationID, state.NamesUnlocked, state.PhotosUnlocked); err != nil {
+			return nil, err
+		}
+	}
+
+	return &state, nil
+}
+
+// unlock flips the features whose threshold the quieter participant has reached, given the
+// fewest messages sent by either side, and records them in Unlocked.
+func (state *RevealState) unlock(fewest, namesThreshold, photosThreshold int) {
+	if !state.NamesUnlocked && fewest >= namesThreshold {
+		state.NamesUnlocked = true
+		state.Unlocked = append(state.Unlocked, RevealNames)
+	}
+	if !state.PhotosUnlocked && fewest >= photosThreshold {
+		state.PhotosUnlocked = true
+		state.Unlocked = append(state.Unlocked, RevealPhotos)
+	}
+}
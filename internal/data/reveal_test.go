@@ -0,0 +1,59 @@
+package data
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRevealStateUnlock(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        RevealState
+		fewest       int
+		wantNames    bool
+		wantPhotos   bool
+		wantUnlocked []string
+	}{
+		{name: "below both thresholds", fewest: 4},
+		{name: "names threshold reached", fewest: 5, wantNames: true, wantUnlocked: []string{RevealNames}},
+		{name: "between thresholds", fewest: 9, wantNames: true, wantUnlocked: []string{RevealNames}},
+		{name: "both thresholds reached", fewest: 10, wantNames: true, wantPhotos: true, wantUnlocked: []string{RevealNames, RevealPhotos}},
+		{
+			name:         "names already unlocked",
+			state:        RevealState{NamesUnlocked: true},
+			fewest:       12,
+			wantNames:    true,
+			wantPhotos:   true,
+			wantUnlocked: []string{RevealPhotos},
+		},
+		{
+			name:       "everything already unlocked",
+			state:      RevealState{NamesUnlocked: true, PhotosUnlocked: true},
+			fewest:     20,
+			wantNames:  true,
+			wantPhotos: true,
+		},
+		{
+			name:       "unlocks are never taken back",
+			state:      RevealState{NamesUnlocked: true, PhotosUnlocked: true},
+			fewest:     0,
+			wantNames:  true,
+			wantPhotos: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			state.unlock(tt.fewest, 5, 10)
+
+			if state.NamesUnlocked != tt.wantNames || state.PhotosUnlocked != tt.wantPhotos {
+				t.Errorf("unlocked names=%v photos=%v, want names=%v photos=%v",
+					state.NamesUnlocked, state.PhotosUnlocked, tt.wantNames, tt.wantPhotos)
+			}
+			if !slices.Equal(state.Unlocked, tt.wantUnlocked) {
+				t.Errorf("Unlocked = %v, want %v", state.Unlocked, tt.wantUnlocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package data
+
+import "time"
+
+// MatchWeights sets how much each signal counts towards a match's score.
+// Every signal is scaled to 0..1 before weighting, so the weights can be read as proportions.
+type MatchWeights struct {
+	// Share of the viewer's interests the candidate also has
+	SharedInterests float64
+	// Like SharedInterests, but each interest weighs its inverse document frequency
+	// over user_interests, so sharing a rare interest counts more than a common one
+	InterestRarity float64
+	// How recently the candidate was active, see RecencyScale
+	Recency float64
+	// How complete the candidate's profile is: pronouns, opening question,
+	// at least three interests, a photo and an audio intro
+	Completeness float64
+}
+
+// DefaultMatchWeights is used when a MatchModel has no weights configured.
+var DefaultMatchWeights = MatchWeights{
+	SharedInterests: 0.35,
+	InterestRarity:  0.35,
+	Recency:         0.15,
+	Completeness:    0.15,
+}
+
+// RecencyScale is the inactivity after which the recency signal has dropped to half.
+const RecencyScale = 7 * 24 * time.Hour
+
+// weights returns the configured weights, or the defaults if none were set.
+func (m MatchModel) weights() MatchWeights {
+	if m.Weights == (MatchWeights{}) {
+		return DefaultMatchWeights
+	}
+	return m.Weights
+}
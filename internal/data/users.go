@@ -8,12 +8,13 @@ import (
 
 // User model represents a user in our database. It no longer has a password.
 type User struct {
-	ID          string    `json:"id"`
-	FirebaseUID string    `json:"firebase_uid"`
-	Email       string    `json:"email"`
-	DisplayName string    `json:"display_name"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string    `json:"id"`
+	FirebaseUID  string    `json:"firebase_uid"`
+	Email        string    `json:"email"`
+	DisplayName  string    `json:"display_name"`
+	HideLastSeen bool      `json:"hide_last_seen"` // Privacy setting: hide online status and last seen from conversation partners
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // UserModel wraps the database connection.
@@ -36,12 +37,12 @@ func (m UserModel) Insert(user *User) error {
 // GetByFirebaseUID retrieves a user by their unique Firebase ID.
 func (m UserModel) GetByFirebaseUID(firebaseUID string) (*User, error) {
 	query := `
-        SELECT id, firebase_uid, email, display_name, created_at, updated_at
+        SELECT id, firebase_uid, email, display_name, hide_last_seen, created_at, updated_at
         FROM users
         WHERE firebase_uid = $1`
 
 	var user User
-	err := m.DB.QueryRow(query, firebaseUID).Scan(&user.ID, &user.FirebaseUID, &user.Email, &user.DisplayName, &user.CreatedAt, &user.UpdatedAt)
+	err := m.DB.QueryRow(query, firebaseUID).Scan(&user.ID, &user.FirebaseUID, &user.Email, &user.DisplayName, &user.HideLastSeen, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -55,12 +56,12 @@ func (m UserModel) GetByFirebaseUID(firebaseUID string) (*User, error) {
 // GetByID retrieves a user by their internal UUID.
 func (m UserModel) GetByID(id string) (*User, error) {
 	query := `
-        SELECT id, firebase_uid, email, display_name, created_at, updated_at
+        SELECT id, firebase_uid, email, display_name, hide_last_seen, created_at, updated_at
         FROM users
         WHERE id = $1`
 
 	var user User
-	err := m.DB.QueryRow(query, id).Scan(&user.ID, &user.FirebaseUID, &user.Email, &user.DisplayName, &user.CreatedAt, &user.UpdatedAt)
+	err := m.DB.QueryRow(query, id).Scan(&user.ID, &user.FirebaseUID, &user.Email, &user.DisplayName, &user.HideLastSeen, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -70,3 +71,13 @@ func (m UserModel) GetByID(id string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// SetHideLastSeen updates the user's presence privacy setting.
+func (m UserModel) SetHideLastSeen(id string, hide bool) error {
+	query := `
+        UPDATE users
+        SET hide_last_seen = $2, updated_at = NOW()
+        WHERE id = $1`
+	_, err := m.DB.Exec(query, id, hide)
+	return err
+}
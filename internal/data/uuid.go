@@ -0,0 +1,21 @@
+package data
+
+// isUUID reports whether s is a UUID in its usual hyphenated form. IDs from clients are
+// checked with it before they reach a uuid parameter, which Postgres would fail to parse.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
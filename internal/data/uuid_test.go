@@ -0,0 +1,28 @@
+package data
+
+import "testing"
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c", want: true},
+		{s: "9B2E6C1A-4F3D-4E8B-A7C5-1D2E3F4A5B6C", want: true},
+		{s: "00000000-0000-0000-0000-000000000000", want: true},
+		{s: "", want: false},
+		{s: "9b2e6c1a4f3d4e8ba7c51d2e3f4a5b6c", want: false},
+		{s: "{9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c}", want: false},
+		{s: "9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6", want: false},
+		{s: "9b2e6c1a-4f3d-4e8b-a7c5-1d2e3f4a5b6c0", want: false},
+		{s: "9b2e6c1a-4f3d-4e8b-a7c51-d2e3f4a5b6c", want: false},
+		{s: "9b2e6c1g-4f3d-4e8b-a7c5-1d2e3f4a5b6c", want: false},
+		{s: "1; DROP TABLE users; --0000000000000", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isUUID(tt.s); got != tt.want {
+			t.Errorf("isUUID(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/shubhranka/spark_api/internal/data"
+	"github.com/shubhranka/spark_api/internal/storage"
+)
+
+const (
+	maxImageBytes = 10 << 20
+	maxAudioBytes = 5 << 20
+	// Room for the multipart boundaries, part headers and text fields around an uploaded file
+	multipartOverhead = 64 << 10
+	// How long a signed download URL stays valid
+	attachmentURLTTL = 15 * time.Minute
+)
+
+// Accepted MIME types, as sniffed from the file contents rather than trusted from the client.
+var attachmentTypes = []struct {
+	mime string
+	kind data.AttachmentKind
+}{
+	{"image/jpeg", data.AttachmentImage},
+	{"image/png", data.AttachmentImage},
+	{"image/webp", data.AttachmentImage},
+	{"image/heic", data.AttachmentImage},
+	{"audio/mpeg", data.AttachmentAudio},
+	{"audio/ogg", data.AttachmentAudio},
+	{"audio/x-m4a", data.AttachmentAudio},
+	{"audio/mp4", data.AttachmentAudio},
+	{"audio/aac", data.AttachmentAudio},
+	{"audio/wav", data.AttachmentAudio},
+	{"audio/webm", data.AttachmentAudio},
+	{"audio/amr", data.AttachmentAudio},
+}
+
+// attachmentKind classifies a sniffed MIME type, reporting false if it is not accepted.
+func attachmentKind(mt *mimetype.MIME) (data.AttachmentKind, bool) {
+	for _, t := range attachmentTypes {
+		if mt.Is(t.mime) {
+			return t.kind, true
+		}
+	}
+	return "", false
+}
+
+// UploadAttachment sends a message carrying an image or voice note, with an optional caption.
+// It expects a multipart form with a "file" field and an optional "content" field.
+func UploadAttachment(c *gin.Context) {
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	// 1. Make sure the user may send into the conversation before storing anything
+	status, err := convModel.StatusFor(conversationID, currentUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidConversationID):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrNotParticipant):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not send message"})
+		}
+		return
+	}
+	switch status {
+	case data.StatusBlocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": data.ErrConversationBlocked.Error()})
+		return
+	case data.StatusDeclined, data.StatusExpired:
+		c.JSON(http.StatusForbidden, gin.H{"error": data.ErrConversationClosed.Error()})
+		return
+	}
+
+	// 2. Read the upload, refusing anything bigger than the largest allowed kind
+	fileHeader := formFile(c, "file", maxImageBytes, "file is too large")
+	if fileHeader == nil {
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+
+	// 3. Decide what it is from its contents and apply that kind's size limit
+	mt := mimetype.Detect(content)
+	kind, ok := attachmentKind(mt)
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported file type " + mt.String()})
+		return
+	}
+	limit := maxImageBytes
+	if kind == data.AttachmentAudio {
+		limit = maxAudioBytes
+	}
+	if len(content) > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		return
+	}
+
+	// 4. Store the file, then the message. The file is removed again if the message is refused.
+	att := &data.Attachment{
+		Kind:       kind,
+		MimeType:   mt.String(),
+		SizeBytes:  int64(len(content)),
+		StorageKey: path.Join("attachments", conversationID, newClientID()+mt.Extension()),
+	}
+	if err := blobStore.Put(c.Request.Context(), att.StorageKey, bytes.NewReader(content), att.SizeBytes, att.MimeType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store file"})
+		return
+	}
+
+	msg, reveal, err := convModel.AddMessageWithAttachment(conversationID, currentUser.ID, c.PostForm("content"), att)
+	if err != nil {
+		deleteBlob(blobStore, att.StorageKey)
+		if isSendForbidden(err) || errors.Is(err, data.ErrPhotosLocked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error sending attachment to conversation %s: %v", conversationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not send message"})
+		return
+	}
+
+	broadcastNewMessage(convModel, msg, reveal)
+	c.JSON(http.StatusCreated, msg)
+}
+
+// GetAttachmentURL returns a short-lived URL to download an attachment from.
+func GetAttachmentURL(c *gin.Context) {
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	att, err := convModel.GetAttachment(c.Param("id"), currentUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAttachmentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrConversationBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve attachment"})
+		}
+		return
+	}
+
+	url, err := blobStore.SignedURL(att.StorageKey, attachmentURLTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not sign attachment URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"expires_at": time.Now().Add(attachmentURLTTL),
+		"attachment": att,
+	})
+}
+
+// ServeBlob downloads a file through a signed URL of the filesystem blob store.
+// It is not behind auth: the signature is the permission.
+func ServeBlob(c *gin.Context) {
+	fsStore, ok := c.MustGet("blobStore").(*storage.FilesystemStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	key := c.Param("key")[1:] // Strip the leading slash of the wildcard
+	file, err := fsStore.Open(key, c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidSignature), errors.Is(err, storage.ErrInvalidKey):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, storage.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not open file"})
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not open file"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime(), file)
+}
+
+// formFile returns the file of a multipart upload. The body is capped before the form is parsed,
+// so an oversized upload is cut off instead of being spooled to disk. If there is no acceptable
+// file it responds with the error and returns nil.
+func formFile(c *gin.Context, field string, maxBytes int64, tooLarge string) *multipart.FileHeader {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+			return nil
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a file is required"})
+		return nil
+	}
+	if fileHeader.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		return nil
+	}
+	return fileHeader
+}
+
+// deleteBlob removes a file that is no longer referenced. Failures only leave an orphan behind, so they are logged.
+func deleteBlob(blobStore storage.BlobStore, key string) {
+	if err := blobStore.Delete(context.Background(), key); err != nil {
+		log.Printf("Error deleting blob %s: %v", key, err)
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/shubhranka/spark_api/internal/audio"
+	"github.com/shubhranka/spark_api/internal/data"
+	"github.com/shubhranka/spark_api/internal/storage"
+)
+
+const (
+	minAudioIntro      = 3 * time.Second
+	maxAudioIntro      = 30 * time.Second
+	maxAudioIntroBytes = 3 << 20
+	audioIntroURLTTL   = time.Hour
+)
+
+// Accepted audio intro types, as sniffed from the file contents, and how to measure them.
+var audioIntroTypes = []struct {
+	mime   string
+	format audio.Format
+}{
+	{"audio/mpeg", audio.MP3},
+	{"audio/x-m4a", audio.MP4},
+	{"audio/mp4", audio.MP4},
+	{"audio/ogg", audio.Ogg},
+	{"audio/wav", audio.WAV},
+}
+
+// audioIntroView is an audio intro as shown to other users.
+type audioIntroView struct {
+	URL        string `json:"url"`
+	DurationMs int    `json:"duration_ms"`
+}
+
+// signAudioIntroURL returns a playable URL for an audio intro, or "" if signing fails.
+func signAudioIntroURL(blobStore storage.BlobStore, key string) string {
+	url, err := blobStore.SignedURL(key, audioIntroURLTTL)
+	if err != nil {
+		log.Printf("Error signing audio intro %s: %v", key, err)
+		return ""
+	}
+	return url
+}
+
+// UploadAudioIntro sets the authenticated user's audio intro, replacing any previous one.
+// It expects a multipart form with a "file" field.
+func UploadAudioIntro(c *gin.Context) {
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	// 1. Read the upload
+	fileHeader := formFile(c, "file", maxAudioIntroBytes, "audio intro is too large")
+	if fileHeader == nil {
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxAudioIntroBytes+1))
+	if err != nil || len(content) > maxAudioIntroBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "audio intro is too large"})
+		return
+	}
+
+	// 2. Check its type and length from the contents
+	mt := mimetype.Detect(content)
+	format, ok := audio.Format(0), false
+	for _, t := range audioIntroTypes {
+		if mt.Is(t.mime) {
+			format, ok = t.format, true
+			break
+		}
+	}
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported audio type " + mt.String()})
+		return
+	}
+	duration, err := audio.Duration(content, format)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if duration < minAudioIntro || duration > maxAudioIntro {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "audio intro must be between " + strconv.Itoa(int(minAudioIntro.Seconds())) +
+				" and " + strconv.Itoa(int(maxAudioIntro.Seconds())) + " seconds long",
+		})
+		return
+	}
+
+	// 3. Store the file, then the intro, then drop the clip it replaced
+	intro := &data.AudioIntro{
+		MimeType:   mt.String(),
+		SizeBytes:  int64(len(content)),
+		DurationMs: int(duration.Milliseconds()),
+		StorageKey: path.Join("audio_intros", user.ID, newClientID()+mt.Extension()),
+	}
+	if err := blobStore.Put(c.Request.Context(), intro.StorageKey, bytes.NewReader(content), intro.SizeBytes, intro.MimeType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store audio intro"})
+		return
+	}
+
+	replacedBlob, err := profileModel.SetAudioIntro(user.ID, intro)
+	if err != nil {
+		deleteBlob(blobStore, intro.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save audio intro"})
+		return
+	}
+	if replacedBlob != "" {
+		deleteBlob(blobStore, replacedBlob)
+	}
+
+	c.JSON(http.StatusOK, audioIntroView{URL: signAudioIntroURL(blobStore, intro.StorageKey), DurationMs: intro.DurationMs})
+}
+
+// DeleteAudioIntro removes the authenticated user's audio intro.
+func DeleteAudioIntro(c *gin.Context) {
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	intro, err := profileModel.DeleteAudioIntro(user.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrAudioIntroNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete audio intro"})
+		return
+	}
+	deleteBlob(blobStore, intro.StorageKey)
+
+	c.JSON(http.StatusOK, gin.H{"message": "audio intro deleted"})
+}
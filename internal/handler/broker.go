@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Delivery is one hub fan-out, as it travels between API instances.
+// Every instance applies it to the clients it holds locally.
+type Delivery struct {
+	// Clients on this conversation's socket receive the event
+	ConversationID string `json:"conversation_id,omitempty"`
+	// Inbox clients of these users receive the event
+	UserIDs []string `json:"user_ids,omitempty"`
+	// The client that caused the event, which should not get it echoed back
+	ExceptClientID string `json:"except_client_id,omitempty"`
+	// Skip every client of this user, e.g. for their own typing indicator
+	ExceptUserID string `json:"except_user_id,omitempty"`
+	// Close disconnects the conversation's clients instead of sending an event
+	Close       bool            `json:"close,omitempty"`
+	CloseReason string          `json:"close_reason,omitempty"`
+	Event       json.RawMessage `json:"event,omitempty"`
+}
+
+// Broker carries deliveries to every API instance, including the one that published them.
+type Broker interface {
+	Publish(d Delivery) error
+	// Subscribe sets the function that applies deliveries on this instance.
+	Subscribe(deliver func(Delivery))
+	Close() error
+}
+
+// memoryBroker delivers straight back to the local hub. Use it for a single node or development.
+type memoryBroker struct {
+	mu      sync.RWMutex
+	deliver func(Delivery)
+}
+
+// NewMemoryBroker returns the in-process broker.
+func NewMemoryBroker() Broker {
+	return &memoryBroker{}
+}
+
+func (b *memoryBroker) Publish(d Delivery) error {
+	b.mu.RLock()
+	deliver := b.deliver
+	b.mu.RUnlock()
+	if deliver != nil {
+		deliver(d)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(deliver func(Delivery)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver = deliver
+}
+
+func (b *memoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Postgres channel the hub deliveries are sent on
+	hubNotifyChannel = "spark_hub"
+	// NOTIFY payloads must stay under 8000 bytes. Bigger deliveries go through the hub_outbox table
+	// and only a reference to the row is sent.
+	maxNotifyPayload = 7900
+	outboxRefPrefix  = "outbox:"
+	// How long outbox rows are kept for slower instances to read them
+	outboxRetention = 5 * time.Minute
+)
+
+// PostgresBroker fans deliveries out to every API replica with LISTEN/NOTIFY.
+type PostgresBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+	done     chan struct{}
+
+	mu      sync.RWMutex
+	deliver func(Delivery)
+}
+
+// NewPostgresBroker starts listening for hub deliveries. dbURL is needed because
+// the listener keeps its own dedicated connection outside the pool.
+func NewPostgresBroker(db *sql.DB, dbURL string) (*PostgresBroker, error) {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Hub listener error: %v", err)
+		}
+		if event == pq.ListenerEventReconnected {
+			log.Println("Hub listener reconnected, deliveries sent while it was down were missed.")
+		}
+	})
+	if err := listener.Listen(hubNotifyChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	b := &PostgresBroker{
+		db:       db,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *PostgresBroker) Publish(d Delivery) error {
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayload {
+		var id int64
+		err := b.db.QueryRow(`INSERT INTO hub_outbox (payload) VALUES ($1) RETURNING id`, string(payload)).Scan(&id)
+		if err != nil {
+			return err
+		}
+		payload = []byte(outboxRefPrefix + strconv.FormatInt(id, 10))
+	}
+
+	_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, hubNotifyChannel, string(payload))
+	return err
+}
+
+func (b *PostgresBroker) Subscribe(deliver func(Delivery)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver = deliver
+}
+
+func (b *PostgresBroker) Close() error {
+	close(b.done)
+	return b.listener.Close()
+}
+
+// run applies incoming notifications until the broker is closed.
+func (b *PostgresBroker) run() {
+	ping := time.NewTicker(90 * time.Second)
+	cleanup := time.NewTicker(time.Minute)
+	defer ping.Stop()
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case n := <-b.listener.Notify:
+			// A nil notification means the connection was re-established
+			if n != nil {
+				b.handle(n.Extra)
+			}
+		case <-ping.C:
+			// Detect a dead listener connection even when nothing is being sent
+			go b.listener.Ping()
+		case <-cleanup.C:
+			cleanupQuery := `DELETE FROM hub_outbox WHERE created_at < NOW() - $1 * INTERVAL '1 second'`
+			if _, err := b.db.Exec(cleanupQuery, int64(outboxRetention.Seconds())); err != nil {
+				log.Printf("Error cleaning up hub outbox: %v", err)
+			}
+		}
+	}
+}
+
+// handle decodes a notification payload, fetching it from the outbox if needed, and delivers it.
+func (b *PostgresBroker) handle(payload string) {
+	if idStr, found := strings.CutPrefix(payload, outboxRefPrefix); found {
+		if err := b.db.QueryRow(`SELECT payload FROM hub_outbox WHERE id = $1`, idStr).Scan(&payload); err != nil {
+			log.Printf("Error loading hub delivery %s from outbox: %v", idStr, err)
+			return
+		}
+	}
+
+	var d Delivery
+	if err := json.Unmarshal([]byte(payload), &d); err != nil {
+		log.Printf("Error decoding hub delivery: %v", err)
+		return
+	}
+
+	b.mu.RLock()
+	deliver := b.deliver
+	b.mu.RUnlock()
+	if deliver != nil {
+		deliver(d)
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// newTestClient returns a client without a connection, whose frames can be read from its send queue.
+func newTestClient(userID string) *client {
+	return &client{
+		id:     newClientID(),
+		userID: userID,
+		send:   make(chan []byte, sendQueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// received drains the frames queued for a client and returns their event types.
+func received(t *testing.T, cl *client) []EventType {
+	t.Helper()
+	var types []EventType
+	for {
+		select {
+		case message := <-cl.send:
+			var event Event
+			if err := json.Unmarshal(message, &event); err != nil {
+				t.Fatalf("queued frame is not an event: %v", err)
+			}
+			types = append(types, event.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func isClosed(cl *client) bool {
+	select {
+	case <-cl.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestMemoryBrokerDeliversToSubscriber(t *testing.T) {
+	b := NewMemoryBroker()
+
+	// Nobody is subscribed yet, publishing is a no-op
+	if err := b.Publish(Delivery{ConversationID: "c1"}); err != nil {
+		t.Fatalf("Publish without subscriber: %v", err)
+	}
+
+	var got []Delivery
+	b.Subscribe(func(d Delivery) { got = append(got, d) })
+	if err := b.Publish(Delivery{ConversationID: "c1", UserIDs: []string{"u1"}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(got) != 1 || got[0].ConversationID != "c1" || len(got[0].UserIDs) != 1 {
+		t.Fatalf("subscriber got %+v, want the one published delivery", got)
+	}
+
+	// A new subscriber replaces the old one
+	var replaced int
+	b.Subscribe(func(Delivery) { replaced++ })
+	b.Publish(Delivery{ConversationID: "c1"})
+	if len(got) != 1 || replaced != 1 {
+		t.Errorf("old subscriber got %d deliveries, new one %d, want 1 and 1", len(got), replaced)
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	const conv = "c1"
+	event := NewEvent(EventMessageCreated, nil)
+
+	tests := []struct {
+		name    string
+		publish func(h *Hub, alice, bob *client)
+		// Which of the clients get the event
+		wantAlice, wantBob, wantAliceInbox, wantBobInbox, wantOther bool
+	}{
+		{
+			name:      "broadcast reaches the conversation only",
+			publish:   func(h *Hub, _, _ *client) { h.Broadcast(conv, event) },
+			wantAlice: true, wantBob: true,
+		},
+		{
+			name:      "publish reaches the conversation and the participants' inboxes",
+			publish:   func(h *Hub, _, _ *client) { h.Publish(conv, []string{"alice", "bob"}, event) },
+			wantAlice: true, wantBob: true, wantAliceInbox: true, wantBobInbox: true,
+		},
+		{
+			name:    "publish except skips the sender's socket",
+			publish: func(h *Hub, alice, _ *client) { h.PublishExcept(conv, []string{"alice", "bob"}, alice, event) },
+			wantBob: true, wantAliceInbox: true, wantBobInbox: true,
+		},
+		{
+			name:         "send to users reaches inboxes only",
+			publish:      func(h *Hub, _, _ *client) { h.SendToUsers([]string{"bob"}, event) },
+			wantBobInbox: true,
+		},
+		{
+			name: "except user skips all of their conversation clients",
+			publish: func(h *Hub, _, _ *client) {
+				h.publish(Delivery{ConversationID: conv, ExceptUserID: "alice"}, event)
+			},
+			wantBob: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			alice, bob, other := newTestClient("alice"), newTestClient("bob"), newTestClient("carol")
+			aliceInbox, bobInbox := newTestClient("alice"), newTestClient("bob")
+			h.addClient(conv, alice)
+			h.addClient(conv, bob)
+			h.addClient("c2", other)
+			h.addInboxClient(aliceInbox)
+			h.addInboxClient(bobInbox)
+
+			tt.publish(h, alice, bob)
+
+			for _, c := range []struct {
+				name string
+				cl   *client
+				want bool
+			}{
+				{"alice", alice, tt.wantAlice},
+				{"bob", bob, tt.wantBob},
+				{"alice's inbox", aliceInbox, tt.wantAliceInbox},
+				{"bob's inbox", bobInbox, tt.wantBobInbox},
+				{"other conversation", other, tt.wantOther},
+			} {
+				got := received(t, c.cl)
+				if c.want && (len(got) != 1 || got[0] != EventMessageCreated) {
+					t.Errorf("%s got %v, want one %s", c.name, got, EventMessageCreated)
+				}
+				if !c.want && len(got) != 0 {
+					t.Errorf("%s got %v, want nothing", c.name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHubCloseConversation(t *testing.T) {
+	h := NewHub()
+	inConv, elsewhere := newTestClient("alice"), newTestClient("alice")
+	h.addClient("c1", inConv)
+	h.addClient("c2", elsewhere)
+
+	h.CloseConversation("c1", "conversation blocked")
+
+	if !isClosed(inConv) {
+		t.Error("client of the closed conversation is still open")
+	}
+	if isClosed(elsewhere) {
+		t.Error("client of another conversation was closed")
+	}
+}
+
+func TestHubEvictsSlowClients(t *testing.T) {
+	h := NewHub()
+	slow, fast := newTestClient("alice"), newTestClient("bob")
+	h.addClient("c1", slow)
+	h.addClient("c1", fast)
+
+	for range sendQueueSize {
+		slow.send <- []byte("{}")
+	}
+	h.Broadcast("c1", NewEvent(EventMessageCreated, nil))
+
+	if !isClosed(slow) {
+		t.Error("client with a full queue was not evicted")
+	}
+	if isClosed(fast) || len(received(t, fast)) != 1 {
+		t.Error("client with room in its queue should get the event and stay connected")
+	}
+	if got := h.Stats().EvictedSlow; got != 1 {
+		t.Errorf("EvictedSlow = %d, want 1", got)
+	}
+}
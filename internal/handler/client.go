@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a single frame to a client.
+	writeWait = 10 * time.Second
+	// Frames queued for a client before it is considered too slow and evicted.
+	sendQueueSize = 64
+	// Largest frame we accept from a client.
+	maxFrameSize = 16 * 1024
+)
+
+// client is a single WebSocket connection registered with the hub.
+// All writes go through its send queue and are performed by one writer goroutine,
+// since gorilla/websocket does not allow concurrent writers on a connection.
+type client struct {
+	id     string // Unique across instances, so a delivery can skip the client that caused it
+	conn   *websocket.Conn
+	userID string
+	send   chan []byte
+
+	// Heartbeat settings copied from the hub when the client is created
+	pingInterval time.Duration
+	pongWait     time.Duration
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeMsg  []byte
+
+	// While replaying missed messages, live frames are held back so they arrive after the replay
+	replayMu  sync.Mutex
+	replaying bool
+	held      [][]byte
+}
+
+// newClient wraps a freshly upgraded connection using the hub's heartbeat settings.
+func (h *Hub) newClient(conn *websocket.Conn, userID string) *client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return &client{
+		id:           newClientID(),
+		conn:         conn,
+		userID:       userID,
+		send:         make(chan []byte, sendQueueSize),
+		pingInterval: h.pingInterval,
+		pongWait:     h.pongWait,
+		done:         make(chan struct{}),
+	}
+}
+
+// newClientID returns a random identifier for a client.
+func newClientID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// prepareReads must be called before the read loop starts. Every pong pushes the read
+// deadline further out, so a client that stops answering pings times out and is cleaned up.
+func (c *client) prepareReads() {
+	c.conn.SetReadLimit(maxFrameSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	})
+}
+
+// writePump drains the send queue into the connection and pings the client every pingInterval.
+// It is the only goroutine that writes to the connection, and it closes the connection when it stops,
+// which ends the read loop.
+func (c *client) writePump() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				log.Printf("Error pinging client of user %s: %v", c.userID, err)
+				return
+			}
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("Error writing to client of user %s: %v", c.userID, err)
+				return
+			}
+		case <-c.done:
+			if c.closeMsg != nil {
+				c.conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(writeWait))
+			}
+			return
+		}
+	}
+}
+
+// enqueue queues a frame without blocking. It returns false if the queue is full,
+// which means the client cannot keep up and should be evicted.
+func (c *client) enqueue(message []byte) bool {
+	c.replayMu.Lock()
+	if c.replaying {
+		defer c.replayMu.Unlock()
+		if len(c.held) >= sendQueueSize {
+			return false
+		}
+		c.held = append(c.held, message)
+		return true
+	}
+	c.replayMu.Unlock()
+	return c.push(message)
+}
+
+// push puts a frame on the send queue without blocking.
+func (c *client) push(message []byte) bool {
+	select {
+	case <-c.done:
+		return true // Already closing, nothing to evict
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// sendEvent queues an event for this client only, evicting it if its queue is full.
+func (c *client) sendEvent(event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshalling event: %v", err)
+		return
+	}
+	if !c.enqueue(message) {
+		WSHub.evictSlowClients([]*client{c})
+	}
+}
+
+// startReplay holds back live frames until finishReplay, so replayed frames go first.
+func (c *client) startReplay() {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+	c.replaying = true
+}
+
+// replay queues a missed frame ahead of the held live frames. Unlike live frames it waits
+// for room in the queue, since a replay can be longer than the queue. It returns false once the client is closed.
+func (c *client) replay(event Event) bool {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshalling event: %v", err)
+		return true
+	}
+	select {
+	case <-c.done:
+		return false
+	case c.send <- message:
+		return true
+	}
+}
+
+// finishReplay releases the live frames held back during the replay.
+// It returns false if they did not fit in the send queue.
+func (c *client) finishReplay() bool {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+	c.replaying = false
+	for _, message := range c.held {
+		if !c.push(message) {
+			return false
+		}
+	}
+	c.held = nil
+	return true
+}
+
+// close stops the writer, sending a close frame with the given code and reason first.
+// It is safe to call more than once.
+func (c *client) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeMsg = websocket.FormatCloseMessage(code, reason)
+		close(c.done)
+	})
+}
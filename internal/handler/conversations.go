@@ -1,11 +1,14 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shubhranka/spark_api/internal/data"
+	"github.com/shubhranka/spark_api/internal/storage"
 )
 
 type startConversationRequest struct {
@@ -17,6 +20,14 @@ type sendMessageRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
+type editMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+type markReadRequest struct {
+	MessageID string `json:"message_id" binding:"required"`
+}
+
 func StartConversation(c *gin.Context) {
 	var req startConversationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -37,12 +48,21 @@ func StartConversation(c *gin.Context) {
 	}
 
 	// Call the data layer to start the conversation
-	conv, err := convModel.Start(currentUser.ID, req.RecipientID, req.Content)
+	conv, removedBlobs, err := convModel.Start(currentUser.ID, req.RecipientID, req.Content)
 	if err != nil {
 		// A more robust error handling would check for specific error types
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start conversation: " + err.Error()})
 		return
 	}
+	if len(removedBlobs) > 0 {
+		blobStore := c.MustGet("blobStore").(storage.BlobStore)
+		for _, key := range removedBlobs {
+			deleteBlob(blobStore, key)
+		}
+	}
+
+	// The recipient learns about the new request on their inbox socket
+	WSHub.SendToUsers([]string{conv.UserAID, conv.UserBID}, NewEvent(EventConversationCreated, conv))
 
 	c.JSON(http.StatusCreated, conv)
 }
@@ -67,9 +87,9 @@ func SendMessage(c *gin.Context) {
 	}
 
 	// This is the model method we will create next
-	msg, err := convModel.AddMessage(conversationID, currentUser.ID, req.Content)
+	msg, reveal, err := convModel.AddMessage(conversationID, currentUser.ID, req.Content)
 	if err != nil {
-		if err.Error() == "cannot send another message until the recipient replies" {
+		if isSendForbidden(err) {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
 		}
@@ -77,13 +97,38 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	msgBytes, err := json.Marshal(msg)
-	if err == nil {
-		WSHub.Broadcast(conversationID, msgBytes)
-	}
+	broadcastNewMessage(convModel, msg, reveal)
 	c.JSON(http.StatusCreated, msg)
 }
 
+// isSendForbidden reports whether AddMessage refused the message because of the conversation rules,
+// as opposed to a server error.
+func isSendForbidden(err error) bool {
+	return errors.Is(err, data.ErrAwaitingReply) ||
+		errors.Is(err, data.ErrConversationBlocked) ||
+		errors.Is(err, data.ErrConversationClosed)
+}
+
+// broadcastNewMessage pushes a freshly stored message, and anything it unlocked, to the live clients.
+// It is shared by the REST endpoint and the WebSocket send frame.
+func broadcastNewMessage(convModel data.ConversationModel, msg *data.Message, reveal *data.RevealState) {
+	publishToConversation(convModel, msg.ConversationID, NewEvent(EventMessageCreated, msg))
+
+	// Let both participants know if this message revealed names or photos
+	if len(reveal.Unlocked) > 0 {
+		publishToConversation(convModel, msg.ConversationID, NewEvent(EventConversationUnlocked, reveal))
+	}
+}
+
+// publishToConversation sends an event to the conversation's sockets and to both participants' inboxes.
+func publishToConversation(convModel data.ConversationModel, conversationID string, event Event) {
+	participants, err := convModel.Participants(conversationID)
+	if err != nil {
+		log.Printf("Error loading participants of conversation %s: %v", conversationID, err)
+	}
+	WSHub.Publish(conversationID, participants, event)
+}
+
 func GetConversationDetails(c *gin.Context) {
 	conversationID := c.Param("id")
 	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
@@ -106,3 +151,245 @@ func GetConversationDetails(c *gin.Context) {
 
 	c.JSON(http.StatusOK, details)
 }
+
+func GetConversationMessages(c *gin.Context) {
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	limit := data.DefaultMessagePageSize
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	page, err := convModel.GetMessages(conversationID, currentUser.ID, c.Query("before"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrNotParticipant):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve messages"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// EditMessage replaces the content of one of the user's own messages within the edit window.
+func EditMessage(c *gin.Context) {
+	var req editMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	msg, err := convModel.EditMessage(conversationID, c.Param("msgId"), currentUser.ID, req.Content)
+	if err != nil {
+		respondMessageChangeError(c, err, "could not edit message")
+		return
+	}
+
+	publishToConversation(convModel, conversationID, NewEvent(EventMessageUpdated, msg))
+	c.JSON(http.StatusOK, msg)
+}
+
+// DeleteMessage unsends one of the user's own messages, leaving a tombstone in the history.
+func DeleteMessage(c *gin.Context) {
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	msg, removedBlob, err := convModel.DeleteMessage(conversationID, c.Param("msgId"), currentUser.ID)
+	if err != nil {
+		respondMessageChangeError(c, err, "could not delete message")
+		return
+	}
+	if removedBlob != "" {
+		deleteBlob(c.MustGet("blobStore").(storage.BlobStore), removedBlob)
+	}
+
+	publishToConversation(convModel, conversationID, NewEvent(EventMessageDeleted, msg))
+	c.JSON(http.StatusOK, msg)
+}
+
+// respondMessageChangeError maps the errors of EditMessage and DeleteMessage to a response.
+func respondMessageChangeError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, data.ErrNotParticipant), errors.Is(err, data.ErrNotMessageSender):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, data.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, data.ErrMessageDeleted), errors.Is(err, data.ErrEditWindowClosed),
+		errors.Is(err, data.ErrConversationBlocked), errors.Is(err, data.ErrConversationClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// MarkConversationRead records how far the user has read and sends a read receipt to the other participant.
+func MarkConversationRead(c *gin.Context) {
+	var req markReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	otherUserID, advanced, err := convModel.MarkRead(conversationID, currentUser.ID, req.MessageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotParticipant):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrMessageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not mark conversation as read"})
+		}
+		return
+	}
+
+	if advanced {
+		publishReadReceipt(conversationID, currentUser.ID, otherUserID, req.MessageID, nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "conversation marked as read"})
+}
+
+// publishReadReceipt tells the other participant, on the conversation socket and their inbox,
+// how far the reader has read. sender is the reader's own socket, if the receipt came from one.
+func publishReadReceipt(conversationID, readerID, otherUserID, messageID string, sender *client) {
+	receipt := ReadReceiptData{ConversationID: conversationID, UserID: readerID, MessageID: messageID}
+	WSHub.PublishExcept(conversationID, []string{otherUserID}, sender, NewEvent(EventMessageRead, receipt))
+}
+
+func BlockConversation(c *gin.Context) {
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	if err := convModel.Block(conversationID, currentUser.ID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotParticipant):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrConversationBlocked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not block conversation: " + err.Error()})
+		}
+		return
+	}
+
+	// Only the blocker's other sessions hear about it; the blocked user just loses the live chat
+	WSHub.SendToUsers([]string{currentUser.ID}, NewEvent(EventConversationStatusChanged, StatusChangedData{ConversationID: conversationID, Status: data.StatusBlocked}))
+	WSHub.CloseConversation(conversationID, "conversation blocked")
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation blocked"})
+}
+
+func UnblockConversation(c *gin.Context) {
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	status, err := convModel.Unblock(conversationID, currentUser.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrConversationNotBlocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not unblock conversation: " + err.Error()})
+		return
+	}
+
+	// Both participants' inboxes go back to showing the conversation as it was
+	publishToConversation(convModel, conversationID, NewEvent(EventConversationStatusChanged, StatusChangedData{ConversationID: conversationID, Status: status}))
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation unblocked"})
+}
+
+func DeclineConversation(c *gin.Context) {
+	conversationID := c.Param("id")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	if err := convModel.Decline(conversationID, currentUser.ID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotParticipant), errors.Is(err, data.ErrNotRecipient):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decline conversation: " + err.Error()})
+		}
+		return
+	}
+
+	// Tell the sender, who may be waiting on the conversation socket or their inbox
+	publishToConversation(convModel, conversationID, NewEvent(EventConversationStatusChanged, StatusChangedData{ConversationID: conversationID, Status: data.StatusDeclined}))
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation declined"})
+}
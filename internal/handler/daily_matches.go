@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shubhranka/spark_api/internal/data"
+)
+
+// Users handled per round of the daily match job
+const dailyMatchJobChunk = 100
+
+// RunDailyMatchJob picks the day's suggestions for every onboarded user who has none yet, checking
+// every interval, and purges old batches. It blocks until ctx is done, so run it in a goroutine.
+// Users who show up before the job reaches them get their batch built on request by GetMatches.
+func RunDailyMatchJob(ctx context.Context, matchModel data.MatchModel, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		buildDailyBatches(ctx, matchModel)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// buildDailyBatches runs one round of RunDailyMatchJob, unless another replica is running one.
+func buildDailyBatches(ctx context.Context, matchModel data.MatchModel) {
+	unlock, ok, err := matchModel.LockDailyBatches(ctx)
+	if err != nil {
+		log.Printf("Error locking daily matches: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	today := data.BatchDay(time.Now())
+	built := 0
+	after := ""
+	for ctx.Err() == nil {
+		userIDs, err := matchModel.UsersWithoutDailyBatch(today, after, dailyMatchJobChunk)
+		if err != nil {
+			log.Printf("Error listing users without daily matches: %v", err)
+			return
+		}
+
+		for _, userID := range userIDs {
+			picked, err := matchModel.BuildDailyBatch(userID, today)
+			if err != nil {
+				log.Printf("Error building daily matches for user %s: %v", userID, err)
+				continue
+			}
+			if picked {
+				built++
+			}
+		}
+
+		// Users who failed are retried next round
+		if len(userIDs) < dailyMatchJobChunk {
+			break
+		}
+		after = userIDs[len(userIDs)-1]
+	}
+	if built > 0 {
+		log.Printf("Built daily matches for %d users.", built)
+	}
+
+	if _, err := matchModel.PurgeDailyBatches(today); err != nil {
+		log.Printf("Error purging old daily matches: %v", err)
+	}
+}
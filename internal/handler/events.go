@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"github.com/shubhranka/spark_api/internal/data"
+)
+
+// EventProtocolVersion is bumped whenever an event payload changes in a breaking way.
+const EventProtocolVersion = 1
+
+// EventType identifies what a server push is about.
+type EventType string
+
+const (
+	EventConversationCreated       EventType = "conversation.created"
+	EventMessageCreated            EventType = "message.created"
+	EventMessageUpdated            EventType = "message.updated"
+	EventMessageDeleted            EventType = "message.deleted"
+	EventReactionAdded             EventType = "reaction.added"
+	EventReactionRemoved           EventType = "reaction.removed"
+	EventMessageRead               EventType = "message.read"
+	EventConversationStatusChanged EventType = "conversation.status_changed"
+	EventConversationUnlocked      EventType = "conversation.unlocked"
+	EventTypingStarted             EventType = "typing.started"
+	EventTypingStopped             EventType = "typing.stopped"
+	EventReplayCompleted           EventType = "replay.completed"
+	EventPresenceChanged           EventType = "presence.changed"
+	EventMatchCreated              EventType = "match.created"
+
+	// Replies to frames sent by the client itself
+	EventAck   EventType = "ack"
+	EventError EventType = "error"
+)
+
+// Event is the envelope around every frame the server pushes over a WebSocket, e.g.
+// {"type":"message.created","v":1,"data":{...}}
+type Event struct {
+	Type EventType `json:"type"`
+	V    int       `json:"v"`
+	Data any       `json:"data"`
+}
+
+// NewEvent wraps a payload in an envelope of the current protocol version.
+func NewEvent(eventType EventType, payload any) Event {
+	return Event{Type: eventType, V: EventProtocolVersion, Data: payload}
+}
+
+// Payloads for the event types above. conversation.created carries a data.Conversation,
+// message.created, message.updated and message.deleted carry a data.Message (a tombstone for
+// message.deleted), conversation.unlocked carries a data.RevealState
+// and presence.changed carries a data.PresenceChange.
+
+// StatusChangedData is the payload of conversation.status_changed.
+type StatusChangedData struct {
+	ConversationID string                  `json:"conversation_id"`
+	Status         data.ConversationStatus `json:"status"`
+}
+
+// ReplayCompletedData is the payload of replay.completed, sent after the missed messages
+// of a reconnecting socket and before live events resume.
+type ReplayCompletedData struct {
+	ConversationID string `json:"conversation_id"`
+	Count          int    `json:"count"`
+	// Truncated means the gap was too long to replay. Fetch the rest from the messages endpoint.
+	Truncated bool `json:"truncated"`
+}
+
+// TypingData is the payload of typing.started and typing.stopped.
+type TypingData struct {
+	ConversationID string `json:"conversation_id"`
+	UserID         string `json:"user_id"`
+}
+
+// ReadReceiptData is the payload of message.read.
+type ReadReceiptData struct {
+	ConversationID string `json:"conversation_id"`
+	UserID         string `json:"user_id"`
+	MessageID      string `json:"message_id"`
+}
+
+// ReactionData is the payload of reaction.added and reaction.removed.
+type ReactionData struct {
+	ConversationID string `json:"conversation_id"`
+	MessageID      string `json:"message_id"`
+	UserID         string `json:"user_id"`
+	Emoji          string `json:"emoji,omitempty"` // Empty for reaction.removed
+}
+
+// MatchCreatedData is the payload of match.created, sent to each user of a mutual like
+// with the other user in it.
+type MatchCreatedData struct {
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+}
+
+// AckData is the payload of ack, confirming a client frame was applied.
+type AckData struct {
+	ClientID string        `json:"client_id,omitempty"`
+	Message  *data.Message `json:"message,omitempty"`
+}
+
+// ErrorData is the payload of error, rejecting a client frame.
+type ErrorData struct {
+	ClientID string `json:"client_id,omitempty"`
+	Error    string `json:"error"`
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// HandleInboxConnection upgrades to a WebSocket that streams events from every conversation
+// the user participates in: new requests, messages and status changes.
+// Example URL: ws://localhost:8080/v1/ws/inbox?token=FIREBASE_ID_TOKEN
+func HandleInboxConnection(c *gin.Context) {
+	currentUser, ok := authenticateSocket(c)
+	if !ok {
+		return
+	}
+
+	// --- Upgrade to WebSocket ---
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade inbox connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	cl := WSHub.newClient(conn, currentUser.ID)
+	cl.prepareReads()
+	go cl.writePump()
+	defer cl.close(websocket.CloseNormalClosure, "")
+
+	WSHub.addInboxClient(cl)
+	defer WSHub.removeInboxClient(cl)
+	WSHub.userConnected(currentUser.ID)
+	defer WSHub.userDisconnected(currentUser.ID)
+
+	// The inbox is receive-only, chatting happens on the conversation sockets.
+	// Reading keeps the connection alive and tells us when it goes away.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			WSHub.noteDisconnect(cl, err)
+			break
+		}
+	}
+}
+
+func (h *Hub) addInboxClient(cl *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.users[cl.userID] == nil {
+		h.users[cl.userID] = make(map[*client]bool)
+	}
+	h.users[cl.userID][cl] = true
+	log.Printf("Inbox client connected for user %s. Total inbox clients: %d", cl.userID, len(h.users[cl.userID]))
+}
+
+func (h *Hub) removeInboxClient(cl *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if clients, found := h.users[cl.userID]; found {
+		delete(clients, cl)
+		if len(clients) == 0 {
+			delete(h.users, cl.userID)
+		}
+	}
+	log.Printf("Inbox client removed for user %s.", cl.userID)
+}
@@ -1,38 +1,148 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shubhranka/spark_api/internal/data"
+	"github.com/shubhranka/spark_api/internal/storage"
 )
 
+// GetMatches returns the first page of the user's suggestions for the day as a bare array,
+// up to the limit parameter. GetMatchesPage is the paged version of this route.
 func GetMatches(c *gin.Context) {
+	page, ok := dailyMatchPage(c, "")
+	if !ok {
+		return
+	}
+	// In a real app, you might get a "no matches" screen, but an empty array is RESTfully correct.
+	c.JSON(http.StatusOK, page.Matches)
+}
+
+// GetMatchesPage returns a page of the user's suggestions for the day and the cursor of the next one.
+// The suggestions are picked once a day, so the pages neither repeat nor skip anyone as scores change.
+func GetMatchesPage(c *gin.Context) {
+	page, ok := dailyMatchPage(c, c.Query("cursor"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// dailyMatchPage serves both versions of the match route. ok is false if the request was answered.
+func dailyMatchPage(c *gin.Context, cursor string) (page *data.MatchPage, ok bool) {
 	// Get the authenticated user's Firebase UID from the context
 	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
 
 	// Get dependencies
 	userModel := c.MustGet("userModel").(data.UserModel)
 	matchModel := c.MustGet("matchModel").(data.MatchModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	limit, ok := matchLimit(c)
+	if !ok {
+		return nil, false
+	}
 
 	// Find our internal user ID from the Firebase UID
 	user, err := userModel.GetByFirebaseUID(firebaseUID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found in local database"})
-		return
+		return nil, false
+	}
+
+	// Today's suggestions are normally picked by the daily job. Pick them now if it hasn't reached this user yet.
+	today := data.BatchDay(time.Now())
+	if cursor == "" {
+		if _, err := matchModel.BuildDailyBatch(user.ID, today); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve matches"})
+			return nil, false
+		}
 	}
 
-	// Call the data layer to find potential matches
-	matches, err := matchModel.GetPotentialMatches(user.ID)
+	// Call the data layer to serve a page of the day's matches
+	page, err = matchModel.GetDailyMatches(user.ID, today, cursor, limit)
 	if err != nil {
+		if errors.Is(err, data.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve matches"})
+		return nil, false
+	}
+
+	signMatchAudioIntros(blobStore, page.Matches)
+	return page, true
+}
+
+// matchLimit reads the limit parameter of the match routes, capped at data.MaxMatchPageSize.
+// ok is false if it was invalid and the request was answered.
+func matchLimit(c *gin.Context) (limit int, ok bool) {
+	limitParam := c.Query("limit")
+	if limitParam == "" {
+		return data.DefaultMatchPageSize, true
+	}
+	parsed, err := strconv.Atoi(limitParam)
+	if err != nil || parsed <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return 0, false
+	}
+	return min(parsed, data.MaxMatchPageSize), true
+}
+
+// signMatchAudioIntros sets the audio intro URLs. Voices can be heard before faces are revealed.
+func signMatchAudioIntros(blobStore storage.BlobStore, matches []data.MatchProfile) {
+	for i := range matches {
+		if matches[i].HasAudioIntro {
+			matches[i].AudioIntroURL = signAudioIntroURL(blobStore, matches[i].AudioIntroKey)
+		}
+	}
+}
+
+// LikeMatch records that the user likes a suggested match. If the like is mutual,
+// both users are told through a match.created event.
+func LikeMatch(c *gin.Context) {
+	recordDecision(c, data.DecisionLike)
+}
+
+// PassMatch records that the user is not interested in a suggested match.
+func PassMatch(c *gin.Context) {
+	recordDecision(c, data.DecisionPass)
+}
+
+func recordDecision(c *gin.Context, decision data.Decision) {
+	targetID := c.Param("userId")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	matchModel := c.MustGet("matchModel").(data.MatchModel)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found in local database"})
 		return
 	}
 
-	// In a real app, you might get a "no matches" screen, but an empty array is RESTfully correct.
-	if matches == nil {
-		matches = []data.MatchProfile{}
+	mutual, err := matchModel.RecordDecision(user.ID, targetID, decision)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrSelfDecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record decision"})
+		}
+		return
+	}
+
+	// Each side learns about the other, still under their pseudonym
+	if mutual {
+		WSHub.SendToUsers([]string{user.ID}, NewEvent(EventMatchCreated, MatchCreatedData{UserID: targetID, DisplayName: data.Pseudonym(targetID)}))
+		WSHub.SendToUsers([]string{targetID}, NewEvent(EventMatchCreated, MatchCreatedData{UserID: user.ID, DisplayName: data.Pseudonym(user.ID)}))
 	}
 
-	c.JSON(http.StatusOK, matches)
+	c.JSON(http.StatusOK, gin.H{"decision": decision, "mutual": mutual})
 }
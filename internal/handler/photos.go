@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shubhranka/spark_api/internal/data"
+	"github.com/shubhranka/spark_api/internal/imaging"
+	"github.com/shubhranka/spark_api/internal/storage"
+)
+
+const (
+	maxPhotoBytes = 10 << 20
+	// Stored photos are re-encoded at these sizes, which also strips metadata such as GPS location
+	photoMaxSide     = 1600
+	thumbnailMaxSide = 320
+	photoURLTTL      = time.Hour
+)
+
+type reorderPhotosRequest struct {
+	PhotoIDs []string `json:"photo_ids" binding:"required"`
+}
+
+// photoView is a profile photo as shown to a viewer. Until photos are unlocked
+// only the blurred placeholder is sent.
+type photoView struct {
+	ID           string `json:"id"`
+	Position     int    `json:"position"`
+	Locked       bool   `json:"locked"`
+	URL          string `json:"url,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	Placeholder  string `json:"placeholder"`
+}
+
+// photoViews prepares photos for a viewer, signing their URLs only if the viewer may see them.
+func photoViews(blobStore storage.BlobStore, photos []data.Photo, unlocked bool) []photoView {
+	views := make([]photoView, 0, len(photos))
+	for _, p := range photos {
+		view := photoView{ID: p.ID, Position: p.Position, Locked: !unlocked, Placeholder: p.Placeholder}
+		if unlocked {
+			var err error
+			if view.URL, err = blobStore.SignedURL(p.StorageKey, photoURLTTL); err != nil {
+				log.Printf("Error signing photo %s: %v", p.ID, err)
+			}
+			if view.ThumbnailURL, err = blobStore.SignedURL(p.ThumbnailKey, photoURLTTL); err != nil {
+				log.Printf("Error signing thumbnail of photo %s: %v", p.ID, err)
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// GetMyPhotos lists the authenticated user's own photos.
+func GetMyPhotos(c *gin.Context) {
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	photos, err := profileModel.GetPhotos(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve photos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"photos": photoViews(blobStore, photos, true)})
+}
+
+// UploadPhoto adds a photo to the end of the authenticated user's profile.
+// It expects a multipart form with a "file" field holding a JPEG or PNG.
+func UploadPhoto(c *gin.Context) {
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	// 1. Read and decode the upload
+	fileHeader := formFile(c, "file", maxPhotoBytes, "photo is too large")
+	if fileHeader == nil {
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxPhotoBytes+1))
+	if err != nil || len(content) > maxPhotoBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "photo is too large"})
+		return
+	}
+
+	img, err := imaging.Decode(content)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 2. Render the stored photo, its thumbnail and the blurred placeholder.
+	// Each is scaled from the previous one, so the full size upload is only gone through once.
+	fitted := imaging.Fit(img, photoMaxSide)
+	full, err := imaging.EncodeJPEG(fitted, 85)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process photo"})
+		return
+	}
+	fittedThumbnail := imaging.Fit(fitted, thumbnailMaxSide)
+	thumbnail, err := imaging.EncodeJPEG(fittedThumbnail, 80)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process photo"})
+		return
+	}
+	placeholder, err := imaging.Placeholder(fittedThumbnail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process photo"})
+		return
+	}
+
+	// 3. Store the files, then the photo. The files are removed again if the photo is refused.
+	name := newClientID()
+	photo := &data.Photo{
+		StorageKey:   path.Join("photos", user.ID, name+".jpg"),
+		ThumbnailKey: path.Join("photos", user.ID, name+"_thumb.jpg"),
+		Placeholder:  placeholder,
+	}
+	ctx := c.Request.Context()
+	if err := blobStore.Put(ctx, photo.StorageKey, bytes.NewReader(full), int64(len(full)), "image/jpeg"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store photo"})
+		return
+	}
+	if err := blobStore.Put(ctx, photo.ThumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+		deleteBlob(blobStore, photo.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store photo"})
+		return
+	}
+
+	if err := profileModel.AddPhoto(user.ID, photo); err != nil {
+		deleteBlob(blobStore, photo.StorageKey)
+		deleteBlob(blobStore, photo.ThumbnailKey)
+		if errors.Is(err, data.ErrTooManyPhotos) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save photo"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, photoViews(blobStore, []data.Photo{*photo}, true)[0])
+}
+
+// DeletePhoto removes one of the authenticated user's photos.
+func DeletePhoto(c *gin.Context) {
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	photo, err := profileModel.DeletePhoto(user.ID, c.Param("photoId"))
+	if err != nil {
+		if errors.Is(err, data.ErrPhotoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete photo"})
+		return
+	}
+	deleteBlob(blobStore, photo.StorageKey)
+	deleteBlob(blobStore, photo.ThumbnailKey)
+
+	c.JSON(http.StatusOK, gin.H{"message": "photo deleted"})
+}
+
+// ReorderPhotos sets the order of the authenticated user's photos.
+func ReorderPhotos(c *gin.Context) {
+	var req reorderPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	photos, err := profileModel.ReorderPhotos(user.ID, req.PhotoIDs)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidPhotoOrder) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reorder photos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"photos": photoViews(blobStore, photos, true)})
+}
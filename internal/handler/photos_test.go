@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/shubhranka/spark_api/internal/data"
+	"github.com/shubhranka/spark_api/internal/storage"
+)
+
+func TestPhotoViews(t *testing.T) {
+	blobStore, err := storage.NewFilesystemStore(t.TempDir(), "http://localhost/files", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewFilesystemStore: %v", err)
+	}
+	photos := []data.Photo{{ID: "p1", Position: 1, StorageKey: "photos/p1.jpg", ThumbnailKey: "photos/p1_thumb.jpg", Placeholder: "LKO2?U%2Tw=w"}}
+
+	t.Run("locked, e.g. once the conversation is blocked", func(t *testing.T) {
+		views := photoViews(blobStore, photos, false)
+		if len(views) != 1 {
+			t.Fatalf("photoViews() returned %d views, want 1", len(views))
+		}
+		if v := views[0]; !v.Locked || v.URL != "" || v.ThumbnailURL != "" || v.Placeholder == "" {
+			t.Errorf("photoViews() = %+v, want a locked placeholder without URLs", v)
+		}
+	})
+
+	t.Run("unlocked", func(t *testing.T) {
+		views := photoViews(blobStore, photos, true)
+		if v := views[0]; v.Locked || v.URL == "" || v.ThumbnailURL == "" {
+			t.Errorf("photoViews() = %+v, want signed URLs", v)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shubhranka/spark_api/internal/data"
+)
+
+// presenceTracker counts this instance's sockets per user. The user's presence across all
+// instances lives in Postgres, so it works with several replicas.
+type presenceTracker struct {
+	mu         sync.Mutex
+	local      map[string]int
+	model      *data.PresenceModel // nil until EnablePresence is called
+	instanceID string
+}
+
+// EnablePresence starts tracking who is online. Every heartbeat interval the instance refreshes
+// its sessions and sweeps the ones left behind by dead instances. heartbeat must be well below
+// data.PresenceStaleAfter.
+func (h *Hub) EnablePresence(model data.PresenceModel, heartbeat time.Duration) {
+	h.presence.mu.Lock()
+	h.presence.model = &model
+	h.presence.instanceID = newClientID()
+	h.presence.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.presenceHeartbeat()
+		}
+	}()
+}
+
+// userConnected is called when one of the user's sockets opens on this instance.
+func (h *Hub) userConnected(userID string) {
+	h.presence.mu.Lock()
+	model, instanceID := h.presence.model, h.presence.instanceID
+	h.presence.local[userID]++
+	first := h.presence.local[userID] == 1
+	h.presence.mu.Unlock()
+
+	if model == nil || !first {
+		return
+	}
+	change, err := model.Connect(instanceID, userID)
+	if err != nil {
+		log.Printf("Error recording presence of user %s: %v", userID, err)
+		return
+	}
+	h.publishPresence(change)
+}
+
+// userDisconnected is called when one of the user's sockets closes on this instance.
+func (h *Hub) userDisconnected(userID string) {
+	h.presence.mu.Lock()
+	model, instanceID := h.presence.model, h.presence.instanceID
+	h.presence.local[userID]--
+	last := h.presence.local[userID] <= 0
+	if last {
+		delete(h.presence.local, userID)
+	}
+	h.presence.mu.Unlock()
+
+	if model == nil || !last {
+		return
+	}
+	change, err := model.Disconnect(instanceID, userID)
+	if err != nil {
+		log.Printf("Error recording last seen of user %s: %v", userID, err)
+		return
+	}
+	h.publishPresence(change)
+}
+
+// presenceHeartbeat keeps this instance's sessions fresh and announces users whose
+// only sessions were on an instance that died.
+func (h *Hub) presenceHeartbeat() {
+	h.presence.mu.Lock()
+	model, instanceID := h.presence.model, h.presence.instanceID
+	userIDs := make([]string, 0, len(h.presence.local))
+	for userID := range h.presence.local {
+		userIDs = append(userIDs, userID)
+	}
+	h.presence.mu.Unlock()
+
+	if err := model.Heartbeat(instanceID, userIDs); err != nil {
+		log.Printf("Error refreshing presence sessions: %v", err)
+	}
+
+	changes, err := model.ExpireStale()
+	if err != nil {
+		log.Printf("Error expiring presence sessions: %v", err)
+		return
+	}
+	for i := range changes {
+		h.publishPresence(&changes[i])
+	}
+}
+
+// publishPresence tells the user's conversation partners that they came online or went offline.
+func (h *Hub) publishPresence(change *data.PresenceChange) {
+	if change == nil || change.Hidden {
+		return
+	}
+
+	h.presence.mu.Lock()
+	model := h.presence.model
+	h.presence.mu.Unlock()
+
+	partners, err := model.Partners(change.UserID)
+	if err != nil {
+		log.Printf("Error loading conversation partners of user %s: %v", change.UserID, err)
+		return
+	}
+	event := NewEvent(EventPresenceChanged, change)
+	for _, p := range partners {
+		h.Publish(p.ConversationID, []string{p.UserID}, event)
+	}
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shubhranka/spark_api/internal/data" // <-- CHECK YOUR PATH
+	"github.com/shubhranka/spark_api/internal/storage"
 )
 
 // GetUserProfile fetches the public profile for a specific user ID.
@@ -18,15 +20,28 @@ func GetUserProfile(c *gin.Context) {
 	}
 
 	// Get dependencies from context
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
 	userModel := c.MustGet("userModel").(data.UserModel) // We need this for the display name
 	profileModel := c.MustGet("profileModel").(data.ProfileModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+	blobStore := c.MustGet("blobStore").(storage.BlobStore)
+
+	viewer, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
 
 	// Define the structure for our public JSON response.
 	// It's very similar to the GetMe response, but we might want to customize it later.
 	type PublicUserProfile struct {
-		ID                string        `json:"id"`
-		DisplayName       string        `json:"display_name"`
-		OnboardingProfile *data.Profile `json:"onboarding_profile"`
+		ID                string          `json:"id"`
+		DisplayName       string          `json:"display_name"`
+		OnboardingProfile *data.Profile   `json:"onboarding_profile"`
+		PhotosUnlocked    bool            `json:"photos_unlocked"`
+		Photos            []photoView     `json:"photos"`
+		HasAudioIntro     bool            `json:"has_audio_intro"`
+		AudioIntro        *audioIntroView `json:"audio_intro,omitempty"`
 	}
 
 	// 1. Fetch basic user info (like display_name) by their internal UUID
@@ -47,11 +62,37 @@ func GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	// 3. Assemble the response
+	// 3. Only show the real name and photos if the viewer has unlocked them in their conversation
+	namesUnlocked, photosUnlocked, err := convModel.RevealBetween(viewer.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check reveal state"})
+		return
+	}
+
+	photos, err := profileModel.GetPhotos(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user photos"})
+		return
+	}
+
+	// Audio intros are shared before anything is unlocked
+	intro, err := profileModel.GetAudioIntro(user.ID)
+	if err != nil && !errors.Is(err, data.ErrAudioIntroNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch audio intro"})
+		return
+	}
+
+	// 4. Assemble the response
 	response := PublicUserProfile{
 		ID:                user.ID,
-		DisplayName:       user.DisplayName,
+		DisplayName:       data.VisibleName(user.ID, user.DisplayName, namesUnlocked),
 		OnboardingProfile: profile,
+		PhotosUnlocked:    photosUnlocked,
+		Photos:            photoViews(blobStore, photos, photosUnlocked),
+	}
+	if intro != nil {
+		response.HasAudioIntro = true
+		response.AudioIntro = &audioIntroView{URL: signAudioIntroURL(blobStore, intro.StorageKey), DurationMs: intro.DurationMs}
 	}
 
 	c.JSON(http.StatusOK, response)
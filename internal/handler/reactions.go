@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shubhranka/spark_api/internal/data"
+)
+
+type setReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// SetReaction adds or replaces the user's emoji reaction on a message.
+func SetReaction(c *gin.Context) {
+	var req setReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	conversationID := c.Param("id")
+	messageID := c.Param("msgId")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	result, err := convModel.SetReaction(conversationID, messageID, currentUser.ID, req.Emoji)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidReaction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, data.ErrAwaitingReply):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			respondMessageChangeError(c, err, "could not add reaction")
+		}
+		return
+	}
+
+	reaction := ReactionData{ConversationID: conversationID, MessageID: messageID, UserID: currentUser.ID, Emoji: result.Reaction.Emoji}
+	publishToConversation(convModel, conversationID, NewEvent(EventReactionAdded, reaction))
+	if result.Activated {
+		publishToConversation(convModel, conversationID, NewEvent(EventConversationStatusChanged, StatusChangedData{ConversationID: conversationID, Status: data.StatusActive}))
+	}
+
+	c.JSON(http.StatusOK, result.Reaction)
+}
+
+// RemoveReaction takes the user's reaction off a message.
+func RemoveReaction(c *gin.Context) {
+	conversationID := c.Param("id")
+	messageID := c.Param("msgId")
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
+
+	currentUser, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authenticated user not found"})
+		return
+	}
+
+	if err := convModel.RemoveReaction(conversationID, messageID, currentUser.ID); err != nil {
+		if errors.Is(err, data.ErrReactionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not remove reaction"})
+		return
+	}
+
+	reaction := ReactionData{ConversationID: conversationID, MessageID: messageID, UserID: currentUser.ID}
+	publishToConversation(convModel, conversationID, NewEvent(EventReactionRemoved, reaction))
+
+	c.JSON(http.StatusOK, gin.H{"message": "reaction removed"})
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shubhranka/spark_api/internal/data"
+)
+
+// RunPendingSweeper expires conversation requests that the recipient never answered within ttl,
+// checking every interval, and notifies the senders. It blocks until ctx is done, so run it in a goroutine.
+func RunPendingSweeper(ctx context.Context, convModel data.ConversationModel, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := convModel.ExpirePending(ttl)
+			if err != nil {
+				log.Printf("Error expiring pending conversations: %v", err)
+				continue
+			}
+			for _, req := range expired {
+				log.Printf("Conversation request %s from user %s expired.", req.ConversationID, req.SenderID)
+				WSHub.Publish(req.ConversationID, []string{req.SenderID, req.RecipientID}, NewEvent(EventConversationStatusChanged, StatusChangedData{ConversationID: req.ConversationID, Status: data.StatusExpired}))
+			}
+		}
+	}
+}
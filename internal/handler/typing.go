@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// typingTimeout is how long a typing indicator lasts without a new typing frame.
+// Clients should resend typing_start every few seconds while the user keeps typing.
+const typingTimeout = 6 * time.Second
+
+type typingKey struct {
+	conversationID string
+	userID         string
+}
+
+// typingTracker remembers who is typing where. Typing state is ephemeral and never persisted.
+type typingTracker struct {
+	mu      sync.Mutex
+	timers  map[typingKey]*time.Timer
+	timeout time.Duration // typingTimeout, shorter in tests
+}
+
+// startTyping tells the other participant that the user started typing, unless they already know,
+// and (re)starts the timer that stops the indicator if no stop frame arrives.
+func (h *Hub) startTyping(conversationID, userID string) {
+	key := typingKey{conversationID: conversationID, userID: userID}
+
+	h.typing.mu.Lock()
+	if timer, found := h.typing.timers[key]; found {
+		timer.Reset(h.typing.timeout)
+		h.typing.mu.Unlock()
+		return
+	}
+	h.typing.timers[key] = time.AfterFunc(h.typing.timeout, func() {
+		h.stopTyping(conversationID, userID)
+	})
+	h.typing.mu.Unlock()
+
+	h.publishTyping(EventTypingStarted, conversationID, userID)
+}
+
+// stopTyping clears the user's typing indicator, if there is one.
+func (h *Hub) stopTyping(conversationID, userID string) {
+	key := typingKey{conversationID: conversationID, userID: userID}
+
+	h.typing.mu.Lock()
+	timer, found := h.typing.timers[key]
+	if found {
+		timer.Stop()
+		delete(h.typing.timers, key)
+	}
+	h.typing.mu.Unlock()
+
+	if found {
+		h.publishTyping(EventTypingStopped, conversationID, userID)
+	}
+}
+
+// publishTyping relays a typing event to the other participant only, never back to the typist's own devices.
+func (h *Hub) publishTyping(eventType EventType, conversationID, userID string) {
+	d := Delivery{ConversationID: conversationID, ExceptUserID: userID}
+	h.publish(d, NewEvent(eventType, TypingData{ConversationID: conversationID, UserID: userID}))
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTypingTestHub returns a hub with a short typing timeout, and the other participant's socket.
+func newTypingTestHub(timeout time.Duration) (*Hub, *client) {
+	h := NewHub()
+	h.typing.timeout = timeout
+	typist, other := newTestClient("alice"), newTestClient("bob")
+	h.addClient("c1", typist)
+	h.addClient("c1", other)
+	return h, other
+}
+
+func TestTypingIndicator(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames func(h *Hub)
+		want   []EventType
+	}{
+		{
+			name:   "start",
+			frames: func(h *Hub) { h.startTyping("c1", "alice") },
+			want:   []EventType{EventTypingStarted},
+		},
+		{
+			name: "repeated starts are sent once",
+			frames: func(h *Hub) {
+				h.startTyping("c1", "alice")
+				h.startTyping("c1", "alice")
+				h.startTyping("c1", "alice")
+			},
+			want: []EventType{EventTypingStarted},
+		},
+		{
+			name: "start then stop",
+			frames: func(h *Hub) {
+				h.startTyping("c1", "alice")
+				h.stopTyping("c1", "alice")
+			},
+			want: []EventType{EventTypingStarted, EventTypingStopped},
+		},
+		{
+			name:   "stop without start is ignored",
+			frames: func(h *Hub) { h.stopTyping("c1", "alice") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, other := newTypingTestHub(time.Minute)
+			tt.frames(h)
+			if got := received(t, other); !slices.Equal(got, tt.want) {
+				t.Errorf("other participant got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypingIndicatorExpires(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	h, other := newTypingTestHub(timeout)
+
+	h.startTyping("c1", "alice")
+	// Keep typing past the first timeout, each frame pushes the expiry out again
+	for range 3 {
+		time.Sleep(timeout / 2)
+		h.startTyping("c1", "alice")
+	}
+	if got := received(t, other); !slices.Equal(got, []EventType{EventTypingStarted}) {
+		t.Fatalf("while typing the other participant got %v, want a single %s", got, EventTypingStarted)
+	}
+
+	// Then go quiet
+	deadline := time.Now().Add(time.Second)
+	var got []EventType
+	for len(got) == 0 && time.Now().Before(deadline) {
+		time.Sleep(timeout / 5)
+		got = received(t, other)
+	}
+	if !slices.Equal(got, []EventType{EventTypingStopped}) {
+		t.Fatalf("after going quiet the other participant got %v, want %s", got, EventTypingStopped)
+	}
+
+	h.typing.mu.Lock()
+	left := len(h.typing.timers)
+	h.typing.mu.Unlock()
+	if left != 0 {
+		t.Errorf("%d typing timers left after expiry, want 0", left)
+	}
+}
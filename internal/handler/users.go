@@ -126,3 +126,30 @@ func GetMe(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// UpdatePrivacy changes the authenticated user's privacy settings.
+func UpdatePrivacy(c *gin.Context) {
+	var req struct {
+		HideLastSeen *bool `json:"hide_last_seen" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	firebaseUID := c.MustGet(authorizationPayloadKey).(string)
+	userModel := c.MustGet("userModel").(data.UserModel)
+
+	user, err := userModel.GetByFirebaseUID(firebaseUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in local db"})
+		return
+	}
+
+	if err := userModel.SetHideLastSeen(user.ID, *req.HideLastSeen); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hide_last_seen": *req.HideLastSeen})
+}
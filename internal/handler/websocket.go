@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
@@ -24,54 +29,157 @@ var upgrader = websocket.Upgrader{
 
 // Hub manages all active client connections.
 type Hub struct {
-	// A map where the key is conversation_id and value is a map of client connections
-	// The inner map's key is the client's connection pointer, value is bool (true)
-	conversations map[string]map[*websocket.Conn]bool
-	mu            sync.RWMutex
+	// A map where the key is conversation_id and value is a map of clients
+	// The inner map's key is the client pointer, value is bool (true)
+	conversations map[string]map[*client]bool
+	// Inbox connections keyed by user_id, these receive events from all of the user's conversations
+	users map[string]map[*client]bool
+	mu    sync.RWMutex
+
+	// Carries deliveries to the clients held by every API instance
+	broker Broker
+
+	// Who is currently typing in which conversation
+	typing typingTracker
+	// Which users have sockets on this instance
+	presence presenceTracker
+
+	// Heartbeat: the server pings every pingInterval and drops clients silent for pongWait
+	pingInterval time.Duration
+	pongWait     time.Duration
+
+	// Counters for monitoring
+	evictedSlow atomic.Int64
+	timedOut    atomic.Int64
 }
 
+const (
+	DefaultPingInterval = 25 * time.Second
+	DefaultPongWait     = 50 * time.Second
+)
+
 func NewHub() *Hub {
-	return &Hub{
-		conversations: make(map[string]map[*websocket.Conn]bool),
+	h := &Hub{
+		conversations: make(map[string]map[*client]bool),
+		users:         make(map[string]map[*client]bool),
+		typing:        typingTracker{timers: make(map[typingKey]*time.Timer), timeout: typingTimeout},
+		presence:      presenceTracker{local: make(map[string]int)},
+		pingInterval:  DefaultPingInterval,
+		pongWait:      DefaultPongWait,
 	}
+	h.SetBroker(NewMemoryBroker())
+	return h
+}
+
+// SetHeartbeat changes the ping interval and pong timeout for clients that connect from now on.
+// pongWait must be longer than pingInterval, and both should stay below the load balancer's idle timeout.
+func (h *Hub) SetHeartbeat(pingInterval, pongWait time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if pongWait <= pingInterval {
+		log.Printf("WebSocket pong wait %s must exceed ping interval %s, keeping %s/%s", pongWait, pingInterval, h.pingInterval, h.pongWait)
+		return
+	}
+	h.pingInterval = pingInterval
+	h.pongWait = pongWait
+}
+
+// HubStats is a snapshot of the hub's connections, for monitoring.
+type HubStats struct {
+	Conversations       int   `json:"conversations"`
+	ConversationClients int   `json:"conversation_clients"`
+	InboxUsers          int   `json:"inbox_users"`
+	InboxClients        int   `json:"inbox_clients"`
+	EvictedSlow         int64 `json:"evicted_slow_total"`
+	TimedOut            int64 `json:"timed_out_total"`
+}
+
+// Stats counts the connected clients.
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	stats := HubStats{
+		Conversations: len(h.conversations),
+		InboxUsers:    len(h.users),
+		EvictedSlow:   h.evictedSlow.Load(),
+		TimedOut:      h.timedOut.Load(),
+	}
+	for _, clients := range h.conversations {
+		stats.ConversationClients += len(clients)
+	}
+	for _, clients := range h.users {
+		stats.InboxClients += len(clients)
+	}
+	return stats
+}
+
+// GetHubStats reports the WebSocket connection counts. It is served on the internal listener only.
+func GetHubStats(c *gin.Context) {
+	c.JSON(http.StatusOK, WSHub.Stats())
+}
+
+// noteDisconnect logs why a client's read loop ended and counts clients that stopped answering pings.
+func (h *Hub) noteDisconnect(cl *client, err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		h.timedOut.Add(1)
+		log.Printf("Client of user %s timed out: %v", cl.userID, err)
+		return
+	}
+	log.Printf("Client disconnected: %v", err)
 }
 
 // Global hub instance
 var WSHub = NewHub()
 
-// HandleWebSocketConnection upgrades the HTTP request to a WebSocket connection.
-func HandleWebSocketConnection(c *gin.Context) {
+// authenticateSocket verifies the Firebase token passed as a query param on a WebSocket upgrade
+// and loads the matching user. On failure it writes the error response and returns false.
+func authenticateSocket(c *gin.Context) (*data.User, bool) {
 	// --- Authorization (Very Important!) ---
 	// Real-time auth is slightly different. We'll pass the token as a query param.
 	// Example URL: ws://localhost:8080/v1/ws/chat/CONVO_ID?token=FIREBASE_ID_TOKEN
 	idToken := c.Query("token")
 	if idToken == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "auth token is required"})
-		return
+		return nil, false
 	}
 
 	// Get authClient and userModel from context
 	authClient := c.MustGet("authClient").(*auth.Client)
 	userModel := c.MustGet("userModel").(data.UserModel)
-	convModel := c.MustGet("conversationModel").(data.ConversationModel)
 
 	token, err := authClient.VerifyIDToken(c, idToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid auth token"})
-		return
+		return nil, false
 	}
 
 	currentUser, err := userModel.GetByFirebaseUID(token.UID)
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	return currentUser, true
+}
+
+// HandleWebSocketConnection upgrades the HTTP request to a WebSocket connection.
+func HandleWebSocketConnection(c *gin.Context) {
+	currentUser, ok := authenticateSocket(c)
+	if !ok {
 		return
 	}
+	convModel := c.MustGet("conversationModel").(data.ConversationModel)
 
 	conversationID := c.Param("id")
-	if _, err := convModel.GetByID(conversationID, currentUser.ID); err != nil {
+	details, err := convModel.GetByID(conversationID, currentUser.ID)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a participant of this conversation"})
 		return
 	}
+	if details.Status == data.StatusBlocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "conversation is blocked"})
+		return
+	}
 
 	// --- Upgrade to WebSocket ---
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -81,38 +189,169 @@ func HandleWebSocketConnection(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	// Register the new client
-	WSHub.addClient(conversationID, conn)
-	defer WSHub.removeClient(conversationID, conn)
+	// Register the new client and start its writer
+	cl := WSHub.newClient(conn, currentUser.ID)
+	cl.prepareReads()
+	go cl.writePump()
+	defer cl.close(websocket.CloseNormalClosure, "")
+
+	// A reconnecting client passes ?since=<last message id or cursor> to get what it missed.
+	// Register first so nothing falls in the gap, but hold live events until the replay is done.
+	since := c.Query("since")
+	if since != "" {
+		cl.startReplay()
+	}
+
+	WSHub.addClient(conversationID, cl)
+	defer WSHub.removeClient(conversationID, cl)
+	WSHub.userConnected(currentUser.ID)
+	defer WSHub.userDisconnected(currentUser.ID)
+	// A typist who disconnects is no longer typing
+	defer WSHub.stopTyping(conversationID, currentUser.ID)
 
-	// Listen for messages from this client
+	if since != "" {
+		replayMissedMessages(cl, convModel, conversationID, since)
+	}
+
+	// Listen for frames from this client
 	for {
-		// For this simple implementation, the client just listens.
-		// Sending happens via the POST /messages endpoint, which then calls WSHub.Broadcast.
-		// A more advanced implementation would read from the socket here.
-		// This loop keeps the connection alive.
-		if _, _, err := conn.ReadMessage(); err != nil {
-			log.Printf("Client disconnected: %v", err)
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			WSHub.noteDisconnect(cl, err)
 			break
 		}
+
+		var frame clientFrame
+		if err := json.Unmarshal(payload, &frame); err != nil {
+			cl.sendEvent(NewEvent(EventError, ErrorData{Error: "invalid frame"}))
+			continue
+		}
+		handleClientFrame(cl, convModel, conversationID, currentUser.ID, frame)
+	}
+}
+
+// replayMissedMessages sends the messages created after `since`, then a replay.completed event,
+// and finally releases the live events held back in the meantime.
+// Clients should ignore a message.created whose ID they already have.
+// If the missed messages cannot be loaded the client gets an error event instead of replay.completed,
+// so it knows it has not caught up and should fetch them from the messages endpoint.
+func replayMissedMessages(cl *client, convModel data.ConversationModel, conversationID, since string) {
+	messages, truncated, err := convModel.GetMessagesSince(conversationID, since, data.MaxReplayMessages)
+	if err != nil {
+		errMsg := "could not replay missed messages"
+		if errors.Is(err, data.ErrInvalidCursor) {
+			errMsg = "invalid since parameter"
+		}
+		cl.replay(NewEvent(EventError, ErrorData{Error: errMsg}))
+		endReplay(cl)
+		return
+	}
+
+	for i := range messages {
+		if !cl.replay(NewEvent(EventMessageCreated, &messages[i])) {
+			return
+		}
+	}
+	cl.replay(NewEvent(EventReplayCompleted, ReplayCompletedData{ConversationID: conversationID, Count: len(messages), Truncated: truncated}))
+	endReplay(cl)
+}
+
+// endReplay releases the live events held back during a replay, evicting the client if they don't fit.
+func endReplay(cl *client) {
+	if !cl.finishReplay() {
+		WSHub.evictSlowClients([]*client{cl})
+	}
+}
+
+// Frame types a client can send over the chat socket.
+// The server replies with an ack or error event.
+const (
+	frameSendMessage = "send_message"
+	frameTypingStart = "typing_start"
+	frameTypingStop  = "typing_stop"
+	frameTyping      = "typing" // Older clients, same as typing_start
+	frameRead        = "read"
+)
+
+// clientFrame is a JSON frame sent by the client over the chat socket.
+type clientFrame struct {
+	Type string `json:"type"`
+	// ClientID is chosen by the client and echoed back in the ack or error,
+	// so the client can match replies to the frames it sent.
+	ClientID  string `json:"client_id,omitempty"`
+	Content   string `json:"content,omitempty"`    // For send_message
+	MessageID string `json:"message_id,omitempty"` // For read
+}
+
+// handleClientFrame applies a single frame received from a participant of a conversation.
+func handleClientFrame(cl *client, convModel data.ConversationModel, conversationID, userID string, frame clientFrame) {
+	switch frame.Type {
+	case frameSendMessage:
+		if frame.Content == "" {
+			cl.sendEvent(NewEvent(EventError, ErrorData{ClientID: frame.ClientID, Error: "content is required"}))
+			return
+		}
+		// Same rules as POST /conversations/:id/messages
+		msg, reveal, err := convModel.AddMessage(conversationID, userID, frame.Content)
+		if err != nil {
+			errMsg := "could not send message"
+			if isSendForbidden(err) {
+				errMsg = err.Error()
+			}
+			cl.sendEvent(NewEvent(EventError, ErrorData{ClientID: frame.ClientID, Error: errMsg}))
+			return
+		}
+		cl.sendEvent(NewEvent(EventAck, AckData{ClientID: frame.ClientID, Message: msg}))
+		// Sending a message ends the typing indicator
+		WSHub.stopTyping(conversationID, userID)
+		broadcastNewMessage(convModel, msg, reveal)
+
+	case frameTypingStart, frameTyping:
+		WSHub.startTyping(conversationID, userID)
+
+	case frameTypingStop:
+		WSHub.stopTyping(conversationID, userID)
+
+	case frameRead:
+		if frame.MessageID == "" {
+			cl.sendEvent(NewEvent(EventError, ErrorData{ClientID: frame.ClientID, Error: "message_id is required"}))
+			return
+		}
+		// Same rules as POST /conversations/:id/read
+		otherUserID, advanced, err := convModel.MarkRead(conversationID, userID, frame.MessageID)
+		if err != nil {
+			errMsg := "could not mark as read"
+			if errors.Is(err, data.ErrMessageNotFound) {
+				errMsg = err.Error()
+			}
+			cl.sendEvent(NewEvent(EventError, ErrorData{ClientID: frame.ClientID, Error: errMsg}))
+			return
+		}
+		if advanced {
+			publishReadReceipt(conversationID, userID, otherUserID, frame.MessageID, cl)
+		}
+		cl.sendEvent(NewEvent(EventAck, AckData{ClientID: frame.ClientID}))
+
+	default:
+		cl.sendEvent(NewEvent(EventError, ErrorData{ClientID: frame.ClientID, Error: "unknown frame type"}))
 	}
 }
 
-func (h *Hub) addClient(conversationID string, conn *websocket.Conn) {
+func (h *Hub) addClient(conversationID string, cl *client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if h.conversations[conversationID] == nil {
-		h.conversations[conversationID] = make(map[*websocket.Conn]bool)
+		h.conversations[conversationID] = make(map[*client]bool)
 	}
-	h.conversations[conversationID][conn] = true
+	h.conversations[conversationID][cl] = true
 	log.Printf("Client connected to conversation %s. Total clients: %d", conversationID, len(h.conversations[conversationID]))
 }
 
-func (h *Hub) removeClient(conversationID string, conn *websocket.Conn) {
+func (h *Hub) removeClient(conversationID string, cl *client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if clients, found := h.conversations[conversationID]; found {
-		delete(clients, conn)
+		delete(clients, cl)
 		if len(clients) == 0 {
 			delete(h.conversations, conversationID)
 		}
@@ -120,15 +359,110 @@ func (h *Hub) removeClient(conversationID string, conn *websocket.Conn) {
 	log.Printf("Client removed from conversation %s.", conversationID)
 }
 
-// Broadcast sends a message to all clients in a specific conversation.
-func (h *Hub) Broadcast(conversationID string, message []byte) {
+// Broadcast sends an event to all clients in a specific conversation.
+func (h *Hub) Broadcast(conversationID string, event Event) {
+	h.publish(Delivery{ConversationID: conversationID}, event)
+}
+
+// Publish sends a conversation event to the clients on that conversation's socket
+// and to the inbox sockets of the given participants.
+func (h *Hub) Publish(conversationID string, participantIDs []string, event Event) {
+	h.publish(Delivery{ConversationID: conversationID, UserIDs: participantIDs}, event)
+}
+
+// PublishExcept is Publish without echoing the event back to the client that caused it.
+func (h *Hub) PublishExcept(conversationID string, participantIDs []string, sender *client, event Event) {
+	d := Delivery{ConversationID: conversationID, UserIDs: participantIDs}
+	if sender != nil {
+		d.ExceptClientID = sender.id
+	}
+	h.publish(d, event)
+}
+
+// SendToUsers sends an event to every inbox connection of the given users.
+func (h *Hub) SendToUsers(userIDs []string, event Event) {
+	h.publish(Delivery{UserIDs: userIDs}, event)
+}
+
+// publish hands a delivery to the broker, which brings it to every API instance.
+func (h *Hub) publish(d Delivery, event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshalling event: %v", err)
+		return
+	}
+	d.Event = message
+	if err := h.currentBroker().Publish(d); err != nil {
+		log.Printf("Error publishing hub delivery: %v", err)
+	}
+}
+
+// deliver applies a delivery to the clients connected to this instance.
+// Sending never blocks: it only queues the frame for each client's writer.
+func (h *Hub) deliver(d Delivery) {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-	if clients, found := h.conversations[conversationID]; found {
-		for client := range clients {
-			if err := client.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("Error broadcasting to client: %v", err)
+	if d.Close {
+		for cl := range h.conversations[d.ConversationID] {
+			cl.close(websocket.ClosePolicyViolation, d.CloseReason)
+		}
+		h.mu.RUnlock()
+		return
+	}
+
+	var slow []*client
+	for cl := range h.conversations[d.ConversationID] {
+		if cl.id == d.ExceptClientID || cl.userID == d.ExceptUserID {
+			continue
+		}
+		if !cl.enqueue(d.Event) {
+			slow = append(slow, cl)
+		}
+	}
+	for _, userID := range d.UserIDs {
+		for cl := range h.users[userID] {
+			if !cl.enqueue(d.Event) {
+				slow = append(slow, cl)
 			}
 		}
 	}
+	h.mu.RUnlock()
+	h.evictSlowClients(slow)
+}
+
+// SetBroker switches the hub to another broadcast backend, e.g. NewPostgresBroker when
+// running several replicas. The hub starts with the in-memory broker.
+func (h *Hub) SetBroker(b Broker) {
+	b.Subscribe(h.deliver)
+	h.mu.Lock()
+	old := h.broker
+	h.broker = b
+	h.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+func (h *Hub) currentBroker() Broker {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.broker
+}
+
+// evictSlowClients disconnects clients whose send queue is full.
+// Their read loops then fail and remove them from the hub.
+func (h *Hub) evictSlowClients(slow []*client) {
+	h.evictedSlow.Add(int64(len(slow)))
+	for _, cl := range slow {
+		log.Printf("Evicting slow client of user %s.", cl.userID)
+		cl.close(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+// CloseConversation disconnects every client listening to a conversation, on every instance.
+// Their read loops then fail and remove them from the hub.
+func (h *Hub) CloseConversation(conversationID, reason string) {
+	d := Delivery{ConversationID: conversationID, Close: true, CloseReason: reason}
+	if err := h.currentBroker().Publish(d); err != nil {
+		log.Printf("Error publishing hub delivery: %v", err)
+	}
 }
@@ -0,0 +1,167 @@
+// Package imaging resizes uploaded photos using only the standard library.
+package imaging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder
+)
+
+// Largest image we agree to decode, to stay safe from decompression bombs
+const maxPixels = 40_000_000
+
+var ErrUnsupportedImage = errors.New("image must be a JPEG or PNG of reasonable size")
+
+// Decode reads a JPEG or PNG, checking its dimensions before decoding the pixels.
+// The result is upright: a JPEG's EXIF orientation, which phone cameras use instead of
+// rotating the pixels, is applied since re-encoding drops the EXIF block.
+func Decode(content []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrUnsupportedImage
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if orientation := exifOrientation(content); orientation != 1 {
+		return &orientedImage{src: img, orientation: orientation}, nil
+	}
+	return img, nil
+}
+
+// Fit scales an image down so its longest side is at most maxSide, keeping the aspect ratio.
+// Each output pixel averages the source pixels it covers, which avoids the aliasing of
+// nearest-neighbour scaling. Images that already fit are copied unchanged.
+func Fit(src image.Image, maxSide int) *image.RGBA {
+	// Scaling doesn't care which way is up, so turn the small result rather than the large source
+	if o, ok := src.(*orientedImage); ok {
+		return orient(fit(o.src, maxSide), o.orientation)
+	}
+	return fit(src, maxSide)
+}
+
+func fit(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w >= h && w > maxSide {
+		dw, dh = maxSide, max(1, h*maxSide/w)
+	} else if h > w && h > maxSide {
+		dw, dh = max(1, w*maxSide/h), maxSide
+	}
+
+	// Source columns covered by each output column
+	cols := make([]int, dw+1)
+	for x := 0; x <= dw; x++ {
+		cols[x] = b.Min.X + x*w/dw
+	}
+
+	addSpan := spanAdder(src)
+	sums := make([][4]uint64, dw)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := b.Min.Y + y*h/dh
+		y1 := max(y0+1, b.Min.Y+(y+1)*h/dh)
+
+		// Go through the source rows in order, which keeps memory access sequential
+		clear(sums)
+		for sy := y0; sy < y1; sy++ {
+			for x := 0; x < dw; x++ {
+				addSpan(&sums[x], sy, cols[x], max(cols[x]+1, cols[x+1]))
+			}
+		}
+
+		for x := 0; x < dw; x++ {
+			n := uint64((y1 - y0) * (max(cols[x]+1, cols[x+1]) - cols[x]))
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(sums[x][0] / n)
+			dst.Pix[i+1] = uint8(sums[x][1] / n)
+			dst.Pix[i+2] = uint8(sums[x][2] / n)
+			dst.Pix[i+3] = uint8(sums[x][3] / n)
+		}
+	}
+	return dst
+}
+
+// spanAdder returns a function adding the 8-bit alpha-premultiplied RGBA of the pixels
+// x0 <= x < x1 of row y to sum. The decoders' usual image types are read straight from
+// their buffers, since At allocates a color for every pixel.
+func spanAdder(src image.Image) func(sum *[4]uint64, y, x0, x1 int) {
+	switch img := src.(type) {
+	case *image.YCbCr:
+		// What the JPEG decoder returns for colour photos
+		return func(sum *[4]uint64, y, x0, x1 int) {
+			for x := x0; x < x1; x++ {
+				ci := img.COffset(x, y)
+				r, g, b := color.YCbCrToRGB(img.Y[img.YOffset(x, y)], img.Cb[ci], img.Cr[ci])
+				sum[0] += uint64(r)
+				sum[1] += uint64(g)
+				sum[2] += uint64(b)
+			}
+			sum[3] += uint64(x1-x0) * 0xff
+		}
+	case *image.RGBA:
+		return func(sum *[4]uint64, y, x0, x1 int) {
+			p := img.Pix[img.PixOffset(x0, y):img.PixOffset(x1, y)]
+			for i := 0; i+3 < len(p); i += 4 {
+				sum[0] += uint64(p[i])
+				sum[1] += uint64(p[i+1])
+				sum[2] += uint64(p[i+2])
+				sum[3] += uint64(p[i+3])
+			}
+		}
+	case *image.NRGBA:
+		return func(sum *[4]uint64, y, x0, x1 int) {
+			p := img.Pix[img.PixOffset(x0, y):img.PixOffset(x1, y)]
+			for i := 0; i+3 < len(p); i += 4 {
+				a := uint64(p[i+3])
+				sum[0] += uint64(p[i]) * a / 0xff
+				sum[1] += uint64(p[i+1]) * a / 0xff
+				sum[2] += uint64(p[i+2]) * a / 0xff
+				sum[3] += a
+			}
+		}
+	case *image.Gray:
+		return func(sum *[4]uint64, y, x0, x1 int) {
+			for _, v := range img.Pix[img.PixOffset(x0, y):img.PixOffset(x1, y)] {
+				sum[0] += uint64(v)
+				sum[1] += uint64(v)
+				sum[2] += uint64(v)
+			}
+			sum[3] += uint64(x1-x0) * 0xff
+		}
+	}
+	return func(sum *[4]uint64, y, x0, x1 int) {
+		for x := x0; x < x1; x++ {
+			r, g, b, a := src.At(x, y).RGBA()
+			sum[0] += uint64(r >> 8)
+			sum[1] += uint64(g >> 8)
+			sum[2] += uint64(b >> 8)
+			sum[3] += uint64(a >> 8)
+		}
+	}
+}
+
+// EncodeJPEG encodes an image as a JPEG of the given quality (1-100).
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Placeholder returns a tiny JPEG of the image as a data URL. Scaled up by the client it shows
+// only a blur of colours, so it can be sent before photos are revealed.
+func Placeholder(img image.Image) (string, error) {
+	tiny, err := EncodeJPEG(Fit(img, 12), 40)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(tiny), nil
+}
@@ -0,0 +1,299 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	green = color.RGBA{0, 0xff, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// quadrants draws a w x h image whose corners are red, green, blue and white, clockwise from the top left.
+func quadrants(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := red
+			switch {
+			case x >= w/2 && y < h/2:
+				c = green
+			case x < w/2 && y >= h/2:
+				c = blue
+			case x >= w/2 && y >= h/2:
+				c = white
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// jpegWithOrientation encodes an image as a JPEG with an EXIF block holding the orientation tag.
+func jpegWithOrientation(t *testing.T, img image.Image, orientation int, order binary.ByteOrder) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)       // IFD0 right after the header
+	order.PutUint16(tiff[8:], 1)       // One entry
+	order.PutUint16(tiff[10:], 0x0112) // Orientation
+	order.PutUint16(tiff[12:], 3)      // SHORT
+	order.PutUint32(tiff[14:], 1)      // One value
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(app1)+2))
+	segment = append(segment, app1...)
+
+	jpg := buf.Bytes()
+	return append(append(append([]byte{}, jpg[:2]...), segment...), jpg[2:]...)
+}
+
+// near reports whether two colours are within the error of a JPEG round trip.
+func near(a, b color.Color) bool {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	diff := func(x, y uint32) uint32 {
+		if x > y {
+			return (x - y) >> 8
+		}
+		return (y - x) >> 8
+	}
+	return diff(ar, br) < 24 && diff(ag, bg) < 24 && diff(ab, bb) < 24
+}
+
+func TestDecodeAppliesEXIFOrientation(t *testing.T) {
+	// The stored image is 80x40. Corners of the upright image: top left, top right, bottom left, bottom right.
+	tests := []struct {
+		orientation int
+		wide        bool
+		corners     [4]color.RGBA
+	}{
+		{1, true, [4]color.RGBA{red, green, blue, white}},
+		{2, true, [4]color.RGBA{green, red, white, blue}},
+		{3, true, [4]color.RGBA{white, blue, green, red}},
+		{4, true, [4]color.RGBA{blue, white, red, green}},
+		{5, false, [4]color.RGBA{red, blue, green, white}},
+		{6, false, [4]color.RGBA{blue, red, white, green}},
+		{7, false, [4]color.RGBA{white, green, blue, red}},
+		{8, false, [4]color.RGBA{green, white, red, blue}},
+	}
+
+	src := quadrants(80, 40)
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for _, tt := range tests {
+			img, err := Decode(jpegWithOrientation(t, src, tt.orientation, order))
+			if err != nil {
+				t.Fatalf("orientation %d: Decode: %v", tt.orientation, err)
+			}
+
+			// Fit turns the scaled down pixels, At turns on the fly; both must agree
+			for name, got := range map[string]image.Image{"Decode": img, "Fit": Fit(img, 1000), "Fit scaled": Fit(img, 40)} {
+				b := got.Bounds()
+				if wide := b.Dx() > b.Dy(); wide != tt.wide || b.Dx()*b.Dy() == 0 {
+					t.Errorf("orientation %d, %s (%v): bounds %v, want wide=%v", tt.orientation, order, name, b, tt.wide)
+					continue
+				}
+				w, h := b.Dx(), b.Dy()
+				points := [4]image.Point{{w / 4, h / 4}, {3 * w / 4, h / 4}, {w / 4, 3 * h / 4}, {3 * w / 4, 3 * h / 4}}
+				for i, p := range points {
+					if c := got.At(b.Min.X+p.X, b.Min.Y+p.Y); !near(c, tt.corners[i]) {
+						t.Errorf("orientation %d, %s (%v): pixel at %v is %v, want %v", tt.orientation, order, name, p, c, tt.corners[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEXIFOrientationIgnoresBrokenData(t *testing.T) {
+	var plain bytes.Buffer
+	jpeg.Encode(&plain, quadrants(16, 8), nil)
+	valid := jpegWithOrientation(t, quadrants(16, 8), 6, binary.BigEndian)
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    int
+	}{
+		{name: "valid", content: valid, want: 6},
+		{name: "no EXIF", content: plain.Bytes(), want: 1},
+		{name: "not a JPEG", content: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "empty", content: nil, want: 1},
+		{name: "truncated segment", content: valid[:20], want: 1},
+		{name: "out of range value", content: jpegWithOrientation(t, quadrants(16, 8), 9, binary.BigEndian), want: 1},
+		{name: "zero value", content: jpegWithOrientation(t, quadrants(16, 8), 0, binary.LittleEndian), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.content); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// A broken TIFF header or IFD offset
+	broken := append([]byte{}, valid...)
+	copy(broken[10:], "XX")
+	if got := exifOrientation(broken); got != 1 {
+		t.Errorf("byte order mark broken: exifOrientation() = %d, want 1", got)
+	}
+	broken = append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(broken[14:], 0xFFFFFF00)
+	if got := exifOrientation(broken); got != 1 {
+		t.Errorf("IFD offset out of range: exifOrientation() = %d, want 1", got)
+	}
+}
+
+func TestFitKeepsAspectRatio(t *testing.T) {
+	tests := []struct {
+		w, h, maxSide int
+		wantW, wantH  int
+	}{
+		{1000, 500, 100, 100, 50},
+		{500, 1000, 100, 50, 100},
+		{800, 800, 100, 100, 100},
+		{1600, 900, 320, 320, 180},
+		{4032, 3024, 1600, 1600, 1200},
+		{50, 20, 100, 50, 20},     // Already fits
+		{100, 100, 100, 100, 100}, // Exactly fits
+		{3000, 1, 100, 100, 1},    // Never collapses to zero
+		{1, 3000, 100, 1, 100},
+	}
+	for _, tt := range tests {
+		got := Fit(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.maxSide).Bounds()
+		if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("Fit(%dx%d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.maxSide, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+// opaqueImage hides an image's concrete type, so Fit falls back to At.
+type opaqueImage struct{ image.Image }
+
+func TestFitFastPathsMatchAt(t *testing.T) {
+	src := quadrants(64, 48)
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, src, &jpeg.Options{Quality: 90})
+	ycbcr, _ := jpeg.Decode(&jpg)
+
+	nrgba := image.NewNRGBA(src.Bounds())
+	gray := image.NewGray(src.Bounds())
+	paletted := image.NewPaletted(src.Bounds(), color.Palette{red, green, blue, white})
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			c := src.At(x, y)
+			nrgba.Set(x, y, color.NRGBA{c.(color.RGBA).R, c.(color.RGBA).G, c.(color.RGBA).B, uint8(x * 4)})
+			gray.Set(x, y, c)
+			paletted.Set(x, y, c)
+		}
+	}
+
+	images := map[string]image.Image{"YCbCr": ycbcr, "RGBA": src, "NRGBA": nrgba, "Gray": gray, "Paletted": paletted}
+	if _, ok := ycbcr.(*image.YCbCr); !ok {
+		t.Fatalf("JPEG decoded to %T, want *image.YCbCr", ycbcr)
+	}
+	for name, img := range images {
+		got, want := Fit(img, 20), Fit(opaqueImage{img}, 20)
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("%s: bounds %v, want %v", name, got.Bounds(), want.Bounds())
+		}
+		for i := range got.Pix {
+			if d := int(got.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+				t.Errorf("%s: byte %d is %d, reading through At gives %d", name, i, got.Pix[i], want.Pix[i])
+				break
+			}
+		}
+	}
+}
+
+func TestFitSubImage(t *testing.T) {
+	// The quadrant starting at (40, 20) is white
+	sub := quadrants(80, 40).SubImage(image.Rect(40, 20, 80, 40))
+	got := Fit(sub, 10)
+	if got.Bounds() != image.Rect(0, 0, 10, 5) {
+		t.Fatalf("bounds %v, want 10x5", got.Bounds())
+	}
+	if c := got.At(5, 2); c != white {
+		t.Errorf("pixel %v, want white", c)
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	for _, size := range []image.Point{{400, 300}, {300, 400}, {5, 5}} {
+		url, err := Placeholder(quadrants(size.X, size.Y))
+		if err != nil {
+			t.Fatalf("Placeholder: %v", err)
+		}
+		encoded, found := strings.CutPrefix(url, "data:image/jpeg;base64,")
+		if !found {
+			t.Fatalf("placeholder %q is not a JPEG data URL", url[:min(len(url), 40)])
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatalf("placeholder is not base64: %v", err)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("placeholder is not a JPEG: %v", err)
+		}
+		if max(cfg.Width, cfg.Height) > 12 {
+			t.Errorf("%v placeholder is %dx%d, want at most 12 pixels a side", size, cfg.Width, cfg.Height)
+		}
+		if (size.X > size.Y) != (cfg.Width > cfg.Height) || (size.X == size.Y) != (cfg.Width == cfg.Height) {
+			t.Errorf("%v placeholder is %dx%d, want the same aspect ratio", size, cfg.Width, cfg.Height)
+		}
+		if len(url) > 1024 {
+			t.Errorf("%v placeholder is %d bytes, want a tiny one", size, len(url))
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	// A valid PNG whose header claims more pixels than we decode
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	huge := buf.Bytes()
+	ihdr := huge[12:29] // Chunk type and data
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	binary.BigEndian.PutUint32(ihdr[8:], 10000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(ihdr))
+	if cfg, err := png.DecodeConfig(bytes.NewReader(huge)); err != nil || cfg.Width != 10000 {
+		t.Fatalf("crafted PNG header: %v %+v", err, cfg)
+	}
+
+	tests := map[string][]byte{
+		"empty":              nil,
+		"text":               []byte("definitely not an image"),
+		"truncated":          jpegWithOrientation(t, quadrants(32, 32), 1, binary.BigEndian)[:200],
+		"decompression bomb": huge,
+	}
+	for name, content := range tests {
+		if _, err := Decode(content); !errors.Is(err, ErrUnsupportedImage) {
+			t.Errorf("%s: Decode error = %v, want ErrUnsupportedImage", name, err)
+		}
+	}
+}
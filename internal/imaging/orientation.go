@@ -0,0 +1,146 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// orientedImage is a decoded image together with the EXIF orientation it must be shown in.
+// It reads as the upright image; Fit turns the pixels only after scaling them down.
+type orientedImage struct {
+	src image.Image
+	// EXIF orientation 2-8, see orient
+	orientation int
+}
+
+func (o *orientedImage) ColorModel() color.Model { return o.src.ColorModel() }
+
+func (o *orientedImage) Bounds() image.Rectangle {
+	b := o.src.Bounds()
+	if o.orientation >= 5 {
+		return image.Rect(0, 0, b.Dy(), b.Dx())
+	}
+	return image.Rect(0, 0, b.Dx(), b.Dy())
+}
+
+func (o *orientedImage) At(x, y int) color.Color {
+	b := o.src.Bounds()
+	sx, sy := sourcePoint(x, y, b.Dx(), b.Dy(), o.orientation)
+	return o.src.At(b.Min.X+sx, b.Min.Y+sy)
+}
+
+// sourcePoint maps a pixel of the upright image to the stored w x h image it comes from.
+func sourcePoint(x, y, w, h, orientation int) (int, int) {
+	switch orientation {
+	case 2: // Mirrored
+		return w - 1 - x, y
+	case 3: // Upside down
+		return w - 1 - x, h - 1 - y
+	case 4: // Upside down and mirrored
+		return x, h - 1 - y
+	case 5: // Transposed
+		return y, x
+	case 6: // Needs a quarter turn clockwise
+		return y, h - 1 - x
+	case 7: // Transversed
+		return w - 1 - y, h - 1 - x
+	case 8: // Needs a quarter turn counter-clockwise
+		return w - 1 - y, x
+	}
+	return x, y
+}
+
+// orient turns and flips an image as its EXIF orientation says.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := sourcePoint(x, y, w, h, orientation)
+			s := src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
+
+// exifOrientation finds the orientation tag in a JPEG's EXIF block. It returns 1, upright,
+// for anything else, including PNGs and broken or missing EXIF data.
+func exifOrientation(content []byte) int {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the marker segments up to the image data, looking for APP1 with an Exif header
+	for i := 2; i+4 <= len(content); {
+		if content[i] != 0xFF {
+			return 1
+		}
+		marker := content[i+1]
+		if marker == 0xFF { // Fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Start of scan, end of image
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(content[i+2:]))
+		if length < 2 || i+2+length > len(content) {
+			return 1
+		}
+		segment := content[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of a TIFF structure.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		const tagOrientation, typeShort = 0x0112, 3
+		if order.Uint16(tiff[entry:]) != tagOrientation {
+			continue
+		}
+		if order.Uint16(tiff[entry+2:]) != typeShort || order.Uint32(tiff[entry+4:]) != 1 {
+			return 1
+		}
+		if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+			return orientation
+		}
+		return 1
+	}
+	return 1
+}
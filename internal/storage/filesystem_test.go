@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBaseURL = "http://localhost:8080/v1/blobs"
+
+func newTestFilesystemStore(t *testing.T) *FilesystemStore {
+	t.Helper()
+	s, err := NewFilesystemStore(t.TempDir(), testBaseURL+"/", []byte("test secret"))
+	if err != nil {
+		t.Fatalf("NewFilesystemStore: %v", err)
+	}
+	return s
+}
+
+// signedParams splits a URL from SignedURL into the key, expires and sig that Open takes.
+func signedParams(t *testing.T, signed string) (key, expires, sig string) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("signed URL %q: %v", signed, err)
+	}
+	key, found := strings.CutPrefix(u.Scheme+"://"+u.Host+u.Path, testBaseURL+"/")
+	if !found {
+		t.Fatalf("signed URL %q is not under %s", signed, testBaseURL)
+	}
+	return key, u.Query().Get("expires"), u.Query().Get("sig")
+}
+
+func TestFilesystemStoreSignedURL(t *testing.T) {
+	s := newTestFilesystemStore(t)
+	const key = "attachments/conv/photo.jpg"
+	if err := s.Put(t.Context(), key, strings.NewReader("jpeg bytes"), 10, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	signed, err := s.SignedURL(key, time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	gotKey, expires, sig := signedParams(t, signed)
+	if gotKey != key {
+		t.Fatalf("signed URL key = %q, want %q", gotKey, key)
+	}
+
+	f, err := s.Open(gotKey, expires, sig)
+	if err != nil {
+		t.Fatalf("Open with a valid signature: %v", err)
+	}
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if string(content) != "jpeg bytes" {
+		t.Errorf("Open read %q, want %q", content, "jpeg bytes")
+	}
+}
+
+func TestFilesystemStoreRejectsBadSignatures(t *testing.T) {
+	s := newTestFilesystemStore(t)
+	const key = "attachments/conv/photo.jpg"
+	const otherKey = "attachments/conv/other.jpg"
+	for _, k := range []string{key, otherKey} {
+		if err := s.Put(t.Context(), k, strings.NewReader("x"), 1, "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	signed, err := s.SignedURL(key, time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	_, expires, sig := signedParams(t, signed)
+
+	expired, err := s.SignedURL(key, -time.Second)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	_, expiredAt, expiredSig := signedParams(t, expired)
+
+	later, _ := strconv.ParseInt(expires, 10, 64)
+	flipped := []byte(sig)
+	flipped[0] ^= 1
+
+	tests := []struct {
+		name              string
+		key, expires, sig string
+	}{
+		{name: "expired", key: key, expires: expiredAt, sig: expiredSig},
+		{name: "expiry pushed out", key: key, expires: strconv.FormatInt(later+3600, 10), sig: sig},
+		{name: "signature of another key", key: otherKey, expires: expires, sig: sig},
+		{name: "tampered signature", key: key, expires: expires, sig: string(flipped)},
+		{name: "truncated signature", key: key, expires: expires, sig: sig[:len(sig)-2]},
+		{name: "missing signature", key: key, expires: expires, sig: ""},
+		{name: "missing expiry", key: key, expires: "", sig: sig},
+		{name: "signed by another secret", key: key, expires: expires, sig: (&FilesystemStore{secret: []byte("other")}).sign(key, expires)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := s.Open(tt.key, tt.expires, tt.sig)
+			if err == nil {
+				f.Close()
+			}
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Open error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestFilesystemStoreKeys(t *testing.T) {
+	s := newTestFilesystemStore(t)
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "a/../../b", "a//b", `a\b`, "a/./b"} {
+		if _, err := s.SignedURL(key, time.Minute); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("SignedURL(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if err := s.Put(t.Context(), key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	// A validly signed URL for a blob that was deleted
+	const key = "attachments/gone.jpg"
+	s.Put(t.Context(), key, strings.NewReader("x"), 1, "image/jpeg")
+	signed, _ := s.SignedURL(key, time.Minute)
+	if err := s.Delete(t.Context(), key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(t.Context(), key); err != nil {
+		t.Errorf("deleting a missing blob: %v", err)
+	}
+	_, expires, sig := signedParams(t, signed)
+	if _, err := s.Open(key, expires, sig); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open of a deleted blob error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid blob key")
+	ErrNotFound   = errors.New("blob not found")
+)
+
+// BlobStore keeps uploaded files such as attachments outside the database.
+// Clients never get direct access, only short-lived signed URLs.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads the blob until ttl has passed.
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// validKey rejects keys that could escape the store, e.g. through "..".
+// Keys are slash-separated paths generated by the API, never by clients.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,3 @@
+ALTER TABLE conversations
+    DROP COLUMN IF EXISTS photos_unlock_threshold,
+    DROP COLUMN IF EXISTS names_unlock_threshold;
@@ -0,0 +1,9 @@
+-- Per-conversation thresholds for the progressive reveal.
+-- A feature unlocks once BOTH participants have sent at least this many messages.
+ALTER TABLE conversations
+    ADD COLUMN names_unlock_threshold INTEGER NOT NULL DEFAULT 10,
+    ADD COLUMN photos_unlock_threshold INTEGER NOT NULL DEFAULT 25;
+
+-- Backfill the message counter, which was never maintained before.
+UPDATE conversations c
+SET message_count = (SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id);
@@ -0,0 +1,3 @@
+-- Remember who blocked a conversation so only they can unblock it
+ALTER TABLE conversations
+    ADD COLUMN blocked_by UUID REFERENCES users(id) ON DELETE SET NULL;
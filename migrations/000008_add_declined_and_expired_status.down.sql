@@ -0,0 +1,12 @@
+-- Postgres cannot drop enum values, so recreate the type without them.
+DROP INDEX IF EXISTS conversations_status_created_at_idx;
+
+DELETE FROM conversations WHERE status IN ('declined', 'expired');
+
+ALTER TYPE conversation_status RENAME TO conversation_status_old;
+CREATE TYPE conversation_status AS ENUM ('pending', 'active', 'blocked');
+ALTER TABLE conversations ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE conversations
+    ALTER COLUMN status TYPE conversation_status USING status::text::conversation_status;
+ALTER TABLE conversations ALTER COLUMN status SET DEFAULT 'pending';
+DROP TYPE conversation_status_old;
@@ -0,0 +1,5 @@
+-- Requests the recipient said no to, and requests nobody answered in time
+ALTER TYPE conversation_status ADD VALUE IF NOT EXISTS 'declined';
+ALTER TYPE conversation_status ADD VALUE IF NOT EXISTS 'expired';
+
+CREATE INDEX ON conversations(status, created_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS messages_conversation_keyset_idx;
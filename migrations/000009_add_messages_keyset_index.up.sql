@@ -0,0 +1,2 @@
+-- Supports keyset pagination of a conversation's history, newest first
+CREATE INDEX messages_conversation_keyset_idx ON messages (conversation_id, created_at DESC, id DESC);
@@ -0,0 +1,8 @@
+-- Hub deliveries too large for a NOTIFY payload. Replicas read them by id, rows expire after a few minutes.
+CREATE TABLE hub_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON hub_outbox(created_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS presence_sessions;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS hide_last_seen,
+    DROP COLUMN IF EXISTS last_seen_at;
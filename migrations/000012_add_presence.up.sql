@@ -0,0 +1,17 @@
+ALTER TABLE users
+    ADD COLUMN last_seen_at TIMESTAMPTZ,
+    -- Privacy setting: hide online status and last seen from conversation partners
+    ADD COLUMN hide_last_seen BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- One row per user per API instance holding a socket for them.
+-- Instances refresh heartbeat_at periodically, rows of a crashed instance go stale and are swept.
+CREATE TABLE presence_sessions (
+    instance_id TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    connected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (instance_id, user_id)
+);
+
+CREATE INDEX ON presence_sessions(user_id);
+CREATE INDEX ON presence_sessions(heartbeat_at);
@@ -0,0 +1,4 @@
+-- Edited messages keep their new content, deleted ones are kept as an empty tombstone
+ALTER TABLE messages
+    ADD COLUMN edited_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at TIMESTAMPTZ;
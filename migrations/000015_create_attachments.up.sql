@@ -0,0 +1,12 @@
+CREATE TYPE attachment_kind AS ENUM ('image', 'audio');
+
+-- Files sent with messages. The files themselves are kept in the blob store under storage_key.
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id UUID NOT NULL UNIQUE REFERENCES messages(id) ON DELETE CASCADE,
+    kind attachment_kind NOT NULL,
+    mime_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
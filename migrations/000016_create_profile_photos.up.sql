@@ -0,0 +1,13 @@
+-- Profile photos, shown in order of position. The image files are kept in the blob store.
+CREATE TABLE profile_photos (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    -- Tiny blurred preview as a data URL, shown until photos are unlocked
+    placeholder TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Deferred so a reorder can swap positions within one transaction
+    UNIQUE (user_id, position) DEFERRABLE INITIALLY DEFERRED
+);
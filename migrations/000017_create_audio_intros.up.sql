@@ -0,0 +1,9 @@
+-- A short voice clip introducing the user. The file is kept in the blob store.
+CREATE TABLE audio_intros (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS match_decisions;
+DROP TYPE IF EXISTS match_decision;
@@ -0,0 +1,13 @@
+CREATE TYPE match_decision AS ENUM ('like', 'pass');
+
+-- What each user did with the people suggested to them
+CREATE TABLE match_decisions (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    decision match_decision NOT NULL,
+    decided_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, target_id)
+);
+
+-- Finds who liked a user, for detecting mutual likes
+CREATE INDEX match_decisions_target_idx ON match_decisions (target_id, user_id) WHERE decision = 'like';
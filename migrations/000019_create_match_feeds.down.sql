@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS match_feed_entries;
+DROP TABLE IF EXISTS match_feeds;
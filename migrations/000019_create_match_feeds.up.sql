@@ -0,0 +1,19 @@
+-- A user's ranking of matches, frozen when they start paging so later pages don't shift
+CREATE TABLE match_feeds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The matches of each feed, in the order they are shown
+CREATE TABLE match_feed_entries (
+    feed_id UUID NOT NULL REFERENCES match_feeds(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    match_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    match_reason TEXT NOT NULL,
+    PRIMARY KEY (feed_id, position)
+);
+
+-- Finds a user's old feeds to clear out
+CREATE INDEX match_feeds_user_idx ON match_feeds (user_id, created_at);
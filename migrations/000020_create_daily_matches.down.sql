@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS daily_matches;
+DROP TABLE IF EXISTS daily_match_batches;
@@ -0,0 +1,22 @@
+-- One row per user per day once their suggestions were picked
+CREATE TABLE daily_match_batches (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    batch_date DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, batch_date)
+);
+
+-- The suggestions of each batch, in the order they are shown
+CREATE TABLE daily_matches (
+    user_id UUID NOT NULL,
+    batch_date DATE NOT NULL,
+    position INTEGER NOT NULL,
+    match_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    match_reason TEXT NOT NULL,
+    PRIMARY KEY (user_id, batch_date, position),
+    FOREIGN KEY (user_id, batch_date) REFERENCES daily_match_batches (user_id, batch_date) ON DELETE CASCADE
+);
+
+-- Finds the users still waiting for today's batch
+CREATE INDEX daily_match_batches_date_idx ON daily_match_batches (batch_date);
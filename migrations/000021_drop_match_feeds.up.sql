@@ -0,0 +1,3 @@
+-- Feeds are picked once a day now, see daily_matches
+DROP TABLE IF EXISTS match_feed_entries;
+DROP TABLE IF EXISTS match_feeds;
@@ -0,0 +1,3 @@
+-- Remember what a conversation was before it got blocked, so unblocking puts it back
+ALTER TABLE conversations
+    ADD COLUMN status_before_block conversation_status;